@@ -0,0 +1,234 @@
+// Package archive create and extract tar, tar.gz, tar.xz, tar.zst and zip archives,
+// selecting members with extglob patterns
+package archive
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/marguerite/go-stdlib/extglob"
+	"github.com/marguerite/go-stdlib/internal"
+	"github.com/ulikunitz/xz"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown archive format")
+	ErrUnsafePath    = errors.New("unsafe path in archive")
+)
+
+// Format an archive format
+type Format int
+
+const (
+	// Auto tell the format by the file name extension
+	Auto Format = iota
+	Tar
+	TarGz
+	TarXz
+	TarZst
+	Zip
+)
+
+func (f Format) String() string {
+	switch f {
+	case Auto:
+		return "auto"
+	case Tar:
+		return "tar"
+	case TarGz:
+		return "tar.gz"
+	case TarXz:
+		return "tar.xz"
+	case TarZst:
+		return "tar.zst"
+	case Zip:
+		return "zip"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// FormatOf the format of an archive by its file name extension
+func FormatOf(name string) (Format, error) {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".tar"):
+		return Tar, nil
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return TarGz, nil
+	case strings.HasSuffix(name, ".tar.xz"), strings.HasSuffix(name, ".txz"):
+		return TarXz, nil
+	case strings.HasSuffix(name, ".tar.zst"), strings.HasSuffix(name, ".tzst"):
+		return TarZst, nil
+	case strings.HasSuffix(name, ".zip"):
+		return Zip, nil
+	}
+	return Auto, fmt.Errorf("%w: %s", ErrUnknownFormat, name)
+}
+
+// resolve the format to use for the archive at path
+func resolve(f Format, path string) (Format, error) {
+	if f != Auto {
+		return f, nil
+	}
+	return FormatOf(path)
+}
+
+// compressor wrap w with the compression of the tar based format f
+func compressor(f Format, w io.Writer) (io.WriteCloser, error) {
+	switch f {
+	case Tar:
+		return nopWriteCloser{w}, nil
+	case TarGz:
+		// the header carries no name or time, for reproducible archives
+		return gzip.NewWriter(w), nil
+	case TarXz:
+		return xz.NewWriter(w)
+	case TarZst:
+		return zstd.NewWriter(w)
+	}
+	return nil, fmt.Errorf("%w: %v", ErrUnknownFormat, f)
+}
+
+// decompressor wrap r with the decompression of the tar based format f
+func decompressor(f Format, r io.Reader) (io.ReadCloser, error) {
+	switch f {
+	case Tar:
+		return ioutil.NopCloser(r), nil
+	case TarGz:
+		return gzip.NewReader(r)
+	case TarXz:
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(xr), nil
+	case TarZst:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("%w: %v", ErrUnknownFormat, f)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// sep the path separator extglob splits patterns at
+const sep = string(extglob.PATH_SEPARATOR)
+
+// memberTree the member names of an archive as a tree extglob can expand patterns in,
+// rooted at "" with absolute paths
+type memberTree struct {
+	children map[string][]string
+	files    map[string]bool
+}
+
+// treePath the path of the member name in memberTree
+func treePath(name string) string {
+	return sep + strings.Replace(strings.Trim(name, "/"), "/", sep, -1)
+}
+
+func newMemberTree(names []string) *memberTree {
+	t := &memberTree{children: make(map[string][]string), files: make(map[string]bool)}
+	seen := map[string]bool{"": true}
+	for _, name := range names {
+		p := treePath(name)
+		if p == sep {
+			continue
+		}
+		if !strings.HasSuffix(name, "/") {
+			t.files[p] = true
+		}
+		// add p and its missing parents
+		for !seen[p] {
+			seen[p] = true
+			parent := p[:strings.LastIndex(p, sep)]
+			t.children[parent] = append(t.children[parent], p)
+			p = parent
+		}
+	}
+	for _, v := range t.children {
+		sort.Strings(v)
+	}
+	return t
+}
+
+func (t *memberTree) list(p string, globalstar, tailing bool) ([]string, error) {
+	p = strings.TrimSuffix(p, sep)
+	if t.files[p] {
+		return []string{p}, nil
+	}
+	if !globalstar {
+		return t.children[p], nil
+	}
+
+	var paths []string
+	var walk func(string)
+	walk = func(p string) {
+		if !tailing || !t.files[p] {
+			paths = append(paths, p)
+		}
+		for _, v := range t.children[p] {
+			walk(v)
+		}
+	}
+	walk(p)
+	return paths, nil
+}
+
+func (t *memberTree) valid(p string) bool {
+	p = strings.TrimSuffix(p, sep)
+	_, ok := t.children[p]
+	return ok || t.files[p]
+}
+
+// match the member names matching any of the extglob patterns, along with
+// everything under the directories matched. patterns are relative to the archive root.
+// the names matched are returned without a trailing slash.
+func match(names, patterns []string) (map[string]bool, error) {
+	t := newMemberTree(names)
+	matched := make(map[string]bool)
+	for _, pattern := range patterns {
+		paths, err := extglob.ExpandFunc(internal.Str2bytes(treePath(filepath.ToSlash(pattern))), t.list, t.valid)
+		if err != nil {
+			return matched, err
+		}
+		for _, p := range paths {
+			matched[strings.Replace(strings.TrimPrefix(p, sep), sep, "/", -1)] = true
+		}
+	}
+
+	for _, name := range names {
+		// a member is matched if it or one of its parents is
+		for p := strings.Trim(name, "/"); len(p) > 0; p = parentOf(p) {
+			if matched[p] {
+				matched[strings.Trim(name, "/")] = true
+				break
+			}
+		}
+	}
+	return matched, nil
+}
+
+// parentOf the parent of a slash separated relative path, "" at the top
+func parentOf(p string) string {
+	i := strings.LastIndexByte(p, '/')
+	if i < 0 {
+		return ""
+	}
+	return p[:i]
+}
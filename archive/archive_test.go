@@ -0,0 +1,35 @@
+package archive
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFormatOf(t *testing.T) {
+	tests := map[string]Format{
+		"a.tar":        Tar,
+		"a.tar.gz":     TarGz,
+		"a.TGZ":        TarGz,
+		"a.tar.xz":     TarXz,
+		"a.tar.zst":    TarZst,
+		"dist/app.zip": Zip,
+	}
+	for name, correct := range tests {
+		if f, err := FormatOf(name); err != nil || f != correct {
+			t.Errorf("[archive]FormatOf test failed, expecting %v, got %v, err %v", correct, f, err)
+		}
+	}
+	if _, err := FormatOf("a.rar"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("[archive]FormatOf test failed, expecting %v, got %v", ErrUnknownFormat, err)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	names := []string{"app/", "app/bin/", "app/bin/app", "app/doc/README", "app/doc/LICENSE", "app/lib/libapp.so"}
+	matched, err := match(names, []string{"app/bin", "app/doc/@(README|NEWS)", "**/*.so"})
+	correct := map[string]bool{"app/bin": true, "app/bin/app": true, "app/doc/README": true, "app/lib/libapp.so": true}
+	if err != nil || !reflect.DeepEqual(matched, correct) {
+		t.Errorf("[archive]match test failed, expecting %v, got %v, err %v", correct, matched, err)
+	}
+}
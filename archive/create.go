@@ -0,0 +1,263 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/marguerite/go-stdlib/extglob"
+	"github.com/marguerite/go-stdlib/fileutils"
+	"github.com/marguerite/go-stdlib/internal"
+)
+
+// CreateOptions options for Create
+type CreateOptions struct {
+	// Format the format of the archive, told by the extension of its name if Auto
+	Format Format
+	// Base the directory member names are relative to. the inputs must be under it.
+	// if empty, member names are the paths of the inputs without the leading separator.
+	Base string
+	// Prefix prepended to every member name, eg: "app-1.0/"
+	Prefix string
+	// Deterministic give every member the same ModTime and root ownership,
+	// so the same inputs always make the same archive
+	Deterministic bool
+	// ModTime the modification time of every member with Deterministic,
+	// the epoch if zero, which is 1980-01-01 for zip
+	ModTime time.Time
+}
+
+// entry an input of Create
+type entry struct {
+	name string
+	path string
+	fi   os.FileInfo
+}
+
+// Create create the archive dst of the files and directories matching the extglob
+// patterns, directories with everything under them. symlinks are archived as such.
+// members are sorted by name. dst is replaced atomically.
+func Create(dst string, patterns []string, opts CreateOptions) error {
+	f, err := resolve(opts.Format, dst)
+	if err != nil {
+		return err
+	}
+
+	entries, err := collect(patterns, opts)
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if f == Zip {
+			pw.CloseWithError(writeZip(pw, entries, opts))
+			return
+		}
+		pw.CloseWithError(writeTar(pw, f, entries, opts))
+	}()
+
+	err = fileutils.WriteAtomic(dst, pr, fileutils.WriteOptions{})
+	// unblock the writer if WriteAtomic stopped reading
+	pr.Close()
+	<-done
+	return err
+}
+
+// collect the inputs matching patterns, sorted by member name
+func collect(patterns []string, opts CreateOptions) ([]entry, error) {
+	base := opts.Base
+	if len(base) > 0 {
+		var err error
+		base, err = filepath.Abs(base)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(map[string]bool)
+	var entries []entry
+	for _, pattern := range patterns {
+		paths := []string{pattern}
+		if extglob.IsPattern(internal.Str2bytes(pattern), true) {
+			var err error
+			paths, err = extglob.Expand(internal.Str2bytes(pattern))
+			if err != nil {
+				return nil, err
+			}
+			if len(paths) == 0 {
+				return nil, &os.PathError{Op: "create", Path: pattern, Err: os.ErrNotExist}
+			}
+		}
+
+		for _, p := range paths {
+			err := filepath.Walk(p, func(path string, fi os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				name, err := memberName(path, base)
+				if err != nil {
+					return err
+				}
+				if len(name) == 0 && len(opts.Prefix) == 0 {
+					// base itself
+					return nil
+				}
+				name = opts.Prefix + name
+				if seen[name] {
+					return nil
+				}
+				seen[name] = true
+				entries = append(entries, entry{name, path, fi})
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	return entries, nil
+}
+
+// memberName the slash separated name of path in the archive
+func memberName(path, base string) (string, error) {
+	if len(base) == 0 {
+		path = filepath.Clean(path)
+		path = strings.TrimPrefix(path, filepath.VolumeName(path))
+		return strings.TrimLeft(filepath.ToSlash(path), "/"), nil
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(base, abs)
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return "", fmt.Errorf("%s is not under %s", path, base)
+	}
+	if rel == "." {
+		return "", nil
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// modTime the modification time of members with Deterministic
+func modTime(opts CreateOptions, epoch time.Time) time.Time {
+	if opts.ModTime.IsZero() {
+		return epoch
+	}
+	return opts.ModTime.Truncate(time.Second)
+}
+
+func writeTar(w io.Writer, f Format, entries []entry, opts CreateOptions) error {
+	cw, err := compressor(f, w)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(cw)
+
+	for _, e := range entries {
+		var link string
+		if e.fi.Mode()&os.ModeSymlink != 0 {
+			link, err = os.Readlink(e.path)
+			if err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(e.fi, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = e.name
+		if e.fi.IsDir() {
+			hdr.Name = strings.TrimSuffix(hdr.Name, "/") + "/"
+		}
+		if opts.Deterministic {
+			hdr.ModTime = modTime(opts, time.Unix(0, 0))
+			hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+			hdr.Uid, hdr.Gid = 0, 0
+			hdr.Uname, hdr.Gname = "", ""
+		}
+
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if e.fi.Mode().IsRegular() {
+			err = copyFile(tw, e.path)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+	return cw.Close()
+}
+
+func writeZip(w io.Writer, entries []entry, opts CreateOptions) error {
+	zw := zip.NewWriter(w)
+
+	for _, e := range entries {
+		hdr, err := zip.FileInfoHeader(e.fi)
+		if err != nil {
+			return err
+		}
+		hdr.Name = e.name
+		hdr.Method = zip.Deflate
+		if e.fi.IsDir() {
+			hdr.Name = strings.TrimSuffix(hdr.Name, "/") + "/"
+			hdr.Method = zip.Store
+		}
+		if opts.Deterministic {
+			hdr.Modified = modTime(opts, time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC))
+		}
+
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		switch {
+		case e.fi.Mode().IsRegular():
+			err = copyFile(fw, e.path)
+		case e.fi.Mode()&os.ModeSymlink != 0:
+			// the content of a symlink is its target
+			var link string
+			link, err = os.Readlink(e.path)
+			if err == nil {
+				_, err = io.WriteString(fw, link)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,97 @@
+package archive
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/marguerite/go-stdlib/dir"
+)
+
+var srcSpec = dir.TreeSpec{
+	{Path: "src/bin/app", Mode: 0755, Content: "app"},
+	{Path: "src/doc/README", Content: "readme"},
+	{Path: "src/empty", Mode: os.ModeDir | 0700},
+	{Path: "src/current", Mode: os.ModeSymlink, Target: "bin/app"},
+	{Path: "src/app.o", Content: "object"},
+}
+
+func TestCreate(t *testing.T) {
+	for _, ext := range []string{"tar", "tar.gz", "tar.xz", "tar.zst", "zip"} {
+		tree, err := dir.TempTree(srcSpec)
+		if err != nil {
+			t.Fatalf("[archive]TempTree failed: %v", err)
+		}
+		defer tree.Cleanup()
+
+		err = Create(tree.Path("app."+ext), []string{tree.Path("src/@(bin|doc|empty|current)")}, CreateOptions{Base: tree.Path("src"), Prefix: "app-1.0/"})
+		if err != nil {
+			t.Errorf("[archive]Create test failed for %s, expecting nil, got %v", ext, err)
+			continue
+		}
+		err = Extract(tree.Path("app."+ext), tree.Path("out"), ExtractOptions{StripComponents: 1})
+		if err != nil {
+			t.Errorf("[archive]Extract test failed for %s, expecting nil, got %v", ext, err)
+			continue
+		}
+
+		spec, err := (&dir.Tree{Root: tree.Path("out")}).Snapshot()
+		correct := dir.TreeSpec{
+			{Path: "bin", Mode: os.ModeDir | 0755},
+			{Path: "bin/app", Mode: 0755, Content: "app"},
+			{Path: "current", Mode: os.ModeSymlink, Target: "bin/app"},
+			{Path: "doc", Mode: os.ModeDir | 0755},
+			{Path: "doc/README", Mode: 0644, Content: "readme"},
+			{Path: "empty", Mode: os.ModeDir | 0700},
+		}
+		if err != nil || !reflect.DeepEqual(spec, correct) {
+			t.Errorf("[archive]Create test failed for %s, expecting %v, got %v, err %v", ext, correct, spec, err)
+		}
+	}
+}
+
+func TestCreateDeterministic(t *testing.T) {
+	for _, ext := range []string{"tar.gz", "zip"} {
+		tree, err := dir.TempTree(srcSpec)
+		if err != nil {
+			t.Fatalf("[archive]TempTree failed: %v", err)
+		}
+		defer tree.Cleanup()
+
+		opts := CreateOptions{Base: tree.Root, Deterministic: true}
+		err = Create(tree.Path("1."+ext), []string{tree.Path("src")}, opts)
+		if err != nil {
+			t.Fatalf("[archive]Create test failed for %s, expecting nil, got %v", ext, err)
+		}
+		os.Chtimes(tree.Path("src/doc/README"), time.Now(), time.Now().Add(-time.Hour))
+		err = Create(tree.Path("2."+ext), []string{tree.Path("src")}, opts)
+		if err != nil {
+			t.Fatalf("[archive]Create test failed for %s, expecting nil, got %v", ext, err)
+		}
+
+		b1, _ := ioutil.ReadFile(tree.Path("1." + ext))
+		b2, _ := ioutil.ReadFile(tree.Path("2." + ext))
+		if len(b1) == 0 || !bytes.Equal(b1, b2) {
+			t.Errorf("[archive]Create test failed for %s, expecting identical archives", ext)
+		}
+	}
+}
+
+func TestCreateOutsideBase(t *testing.T) {
+	tree, err := dir.TempTree(srcSpec)
+	if err != nil {
+		t.Fatalf("[archive]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	err = Create(tree.Path("app.tar"), []string{tree.Path("src/app.o")}, CreateOptions{Base: tree.Path("src/doc")})
+	if err == nil {
+		t.Errorf("[archive]Create test failed, expecting an error for inputs outside of Base, got nil")
+	}
+	if _, err := os.Lstat(tree.Path("app.tar")); !os.IsNotExist(err) {
+		t.Errorf("[archive]Create test failed, expecting no archive left, got %v", err)
+	}
+}
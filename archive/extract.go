@@ -0,0 +1,453 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/marguerite/go-stdlib/dir"
+)
+
+// ExtractOptions options for Extract
+type ExtractOptions struct {
+	// Format the format of the archive, told by the extension of its name if Auto
+	Format Format
+	// StripComponents drop that many leading components from member names,
+	// members with no more components are skipped, like `tar --strip-components`
+	StripComponents int
+	// Includes extract only the members matching one of the extglob patterns,
+	// and everything under the directories matching, all if empty.
+	// patterns are matched against the member names before stripping.
+	Includes []string
+	// PreserveOwner give members the owner and group recorded in a tar archive
+	PreserveOwner bool
+	// SkipUnsafe skip unsafe members instead of failing with ErrUnsafePath
+	SkipUnsafe bool
+}
+
+// member a member of an archive
+type member struct {
+	name string
+	mode os.FileMode
+	// link the target of a symlink, or the member a hard link links to
+	link     string
+	hardlink bool
+	mtime    time.Time
+	uid, gid int
+	owner    bool
+	open     func() (io.ReadCloser, error)
+}
+
+// extractor the state of an Extract
+type extractor struct {
+	dst  string
+	opts ExtractOptions
+	// matched the members Includes matched
+	matched map[string]bool
+	dirs    []*member
+	// links the symlinks created, by their member names
+	links map[string]string
+}
+
+// Extract extract the archive src into the directory dst, which is created if missing.
+// members are confined to dst: a name with a ".." component, a symlink pointing
+// outside of dst, resolved against what is on disk then and once everything is
+// extracted, or a member under a symlink is unsafe. a leading "/" is removed
+// from names. files, directories, symlinks and hard links are extracted, other
+// members are skipped. permissions are kept without the setuid, setgid and sticky bits.
+func Extract(src, dst string, opts ExtractOptions) error {
+	f, err := resolve(opts.Format, src)
+	if err != nil {
+		return err
+	}
+	dst, err = filepath.Abs(dst)
+	if err != nil {
+		return err
+	}
+	_, err = dir.MkdirPWithOptions(dst, dir.MkdirOptions{})
+	if err != nil {
+		return err
+	}
+
+	x := &extractor{dst: dst, opts: opts, links: make(map[string]string)}
+	if len(opts.Includes) > 0 {
+		var names []string
+		err = walk(src, f, func(m *member) error {
+			if name, ok := clean(m.name); ok {
+				if m.mode.IsDir() {
+					name += "/"
+				}
+				names = append(names, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		x.matched, err = match(names, opts.Includes)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = walk(src, f, x.extract)
+	if err != nil {
+		return err
+	}
+	return x.finish()
+}
+
+// walk call fn for every member of the archive src in order
+func walk(src string, f Format, fn func(*member) error) error {
+	if f == Zip {
+		return walkZip(src, fn)
+	}
+
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	r, err := decompressor(f, bufio.NewReader(file))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeRegA, tar.TypeDir, tar.TypeSymlink, tar.TypeLink:
+		default:
+			// pax global headers, devices, fifos and the like
+			continue
+		}
+		m := &member{
+			name:     hdr.Name,
+			mode:     hdr.FileInfo().Mode(),
+			link:     hdr.Linkname,
+			hardlink: hdr.Typeflag == tar.TypeLink,
+			mtime:    hdr.ModTime,
+			uid:      hdr.Uid,
+			gid:      hdr.Gid,
+			owner:    true,
+			open:     func() (io.ReadCloser, error) { return ioutil.NopCloser(tr), nil },
+		}
+		err = fn(m)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func walkZip(src string, fn func(*member) error) error {
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, zf := range zr.File {
+		m := &member{name: zf.Name, mode: zf.Mode(), mtime: zf.Modified, open: zf.Open}
+		if m.mode&os.ModeSymlink != 0 {
+			// the content of a symlink is its target
+			r, err := zf.Open()
+			if err != nil {
+				return err
+			}
+			b, err := ioutil.ReadAll(io.LimitReader(r, 4096))
+			r.Close()
+			if err != nil {
+				return err
+			}
+			m.link = string(b)
+		}
+		err = fn(m)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clean the slash separated name relative to the destination of the member name,
+// false if it has a ".." component, which is unsafe
+func clean(name string) (string, bool) {
+	var parts []string
+	for _, p := range strings.Split(name, "/") {
+		switch p {
+		case "", ".":
+		case "..":
+			return "", false
+		default:
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, "/"), true
+}
+
+// strip drop the leading n components of name, "" if there is nothing left
+func strip(name string, n int) string {
+	for ; n > 0 && len(name) > 0; n-- {
+		i := strings.IndexByte(name, '/')
+		if i < 0 {
+			return ""
+		}
+		name = name[i+1:]
+	}
+	return name
+}
+
+// escapes whether the symlink name pointing to target leads outside of the destination,
+// lexically. see extractor.confined for what is on disk.
+func escapes(name, target string) bool {
+	if path.IsAbs(target) || filepath.IsAbs(filepath.FromSlash(target)) {
+		return true
+	}
+	p := path.Join(path.Dir(name), target)
+	return p == ".." || strings.HasPrefix(p, "../")
+}
+
+// unsafe fail with ErrUnsafePath for the member name, or skip it with SkipUnsafe
+func (x *extractor) unsafe(name string) error {
+	if x.opts.SkipUnsafe {
+		return nil
+	}
+	return &os.PathError{Op: "extract", Path: name, Err: ErrUnsafePath}
+}
+
+// target the path of the member name under dst, its missing parents are created.
+// it is unsafe if one of its parents is a symlink or not a directory.
+func (x *extractor) target(name string) (string, bool, error) {
+	parent := path.Dir(name)
+	p := x.dst
+	for _, part := range strings.Split(parent, "/") {
+		if part == "." {
+			break
+		}
+		p = filepath.Join(p, part)
+		fi, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			_, err = dir.MkdirPWithOptions(filepath.Join(x.dst, filepath.FromSlash(parent)), dir.MkdirOptions{Mode: 0755})
+			if err != nil {
+				return "", true, err
+			}
+			break
+		}
+		if err != nil {
+			return "", true, err
+		}
+		if !fi.IsDir() {
+			return "", false, nil
+		}
+	}
+	return filepath.Join(x.dst, filepath.FromSlash(name)), true, nil
+}
+
+func (x *extractor) extract(m *member) error {
+	name, ok := clean(m.name)
+	if !ok {
+		return x.unsafe(m.name)
+	}
+	if x.matched != nil && !x.matched[name] {
+		return nil
+	}
+	name = strip(name, x.opts.StripComponents)
+	if len(name) == 0 {
+		return nil
+	}
+
+	var link string
+	switch {
+	case m.hardlink:
+		link, ok = clean(m.link)
+		if !ok {
+			return x.unsafe(m.name)
+		}
+		link = strip(link, x.opts.StripComponents)
+		if len(link) == 0 {
+			return nil
+		}
+	case m.mode&os.ModeSymlink != 0:
+		if escapes(name, m.link) {
+			return x.unsafe(m.name)
+		}
+	case !m.mode.IsDir() && !m.mode.IsRegular():
+		// devices, fifos and sockets
+		return nil
+	}
+
+	target, safe, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	if !safe {
+		return x.unsafe(m.name)
+	}
+
+	// replace what is there, a symlink must not be written through
+	fi, err := os.Lstat(target)
+	switch {
+	case err == nil && fi.IsDir() && m.mode.IsDir():
+	case err == nil:
+		err = os.Remove(target)
+		if err != nil {
+			return err
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	switch {
+	case m.hardlink:
+		linked, safe, err := x.target(link)
+		if err != nil {
+			return err
+		}
+		if !safe {
+			return x.unsafe(m.name)
+		}
+		err = os.Link(linked, target)
+		if err != nil {
+			return err
+		}
+		// a hard link to a symlink is a symlink of its own, relative to another directory
+		if fi, err := os.Lstat(target); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+			_, err = x.confined(m.name, target)
+			return err
+		}
+		return nil
+	case m.mode&os.ModeSymlink != 0:
+		err = os.Symlink(m.link, target)
+		if err == nil {
+			var kept bool
+			kept, err = x.confined(m.name, target)
+			if err == nil && !kept {
+				return nil
+			}
+		}
+	case m.mode.IsDir():
+		// writable until finish
+		err = os.Mkdir(target, 0700)
+		if os.IsExist(err) {
+			err = nil
+		}
+		x.dirs = append(x.dirs, &member{name: target, mode: m.mode, mtime: m.mtime})
+	default:
+		err = x.write(m, target)
+	}
+	if err != nil {
+		return err
+	}
+
+	if x.opts.PreserveOwner && m.owner {
+		err = os.Lchown(target, m.uid, m.gid)
+		if err != nil {
+			return err
+		}
+	}
+	if m.mode.IsRegular() {
+		err = os.Chmod(target, m.mode.Perm())
+		if err == nil {
+			err = os.Chtimes(target, m.mtime, m.mtime)
+		}
+	}
+	return err
+}
+
+// write the content of the file m to target
+func (x *extractor) write(m *member, target string) error {
+	r, err := m.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// confined check the symlink target created for the member name stays within dst
+// with the symlinks on disk resolved, the symlink is removed if not
+func (x *extractor) confined(name, target string) (kept bool, err error) {
+	if dir.IsWithin(x.dst, target) {
+		x.links[name] = target
+		return true, nil
+	}
+	err = os.Remove(target)
+	if err != nil {
+		return false, err
+	}
+	return false, x.unsafe(name)
+}
+
+// finish check the symlinks again, as those extracted later may lead the ones before
+// out of dst, then give the directories their modes and times, the deepest first
+func (x *extractor) finish() error {
+	// removing a symlink may lead others elsewhere again
+	for removed := true; removed; {
+		removed = false
+		names := make([]string, 0, len(x.links))
+		for name := range x.links {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			target := x.links[name]
+			if fi, err := os.Lstat(target); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+				// replaced since
+				delete(x.links, name)
+				continue
+			}
+			if dir.IsWithin(x.dst, target) {
+				continue
+			}
+			delete(x.links, name)
+			err := os.Remove(target)
+			if err != nil {
+				return err
+			}
+			err = x.unsafe(name)
+			if err != nil {
+				return err
+			}
+			removed = true
+		}
+	}
+
+	sort.Slice(x.dirs, func(i, j int) bool { return x.dirs[i].name > x.dirs[j].name })
+	for _, d := range x.dirs {
+		err := os.Chmod(d.name, d.mode.Perm())
+		if err != nil {
+			return err
+		}
+		err = os.Chtimes(d.name, d.mtime, d.mtime)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
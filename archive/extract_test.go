@@ -0,0 +1,182 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/marguerite/go-stdlib/dir"
+)
+
+// writeTestTar write a tar archive of hdrs, regular files hold their names
+func writeTestTar(path string, hdrs []*tar.Header) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	for _, hdr := range hdrs {
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = int64(len(hdr.Name))
+		}
+		if hdr.Mode == 0 && hdr.Typeflag != tar.TypeXGlobalHeader {
+			hdr.Mode = 0644
+		}
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			tw.Write([]byte(hdr.Name))
+		}
+	}
+	return tw.Close()
+}
+
+func TestExtractUnsafe(t *testing.T) {
+	tests := [][]*tar.Header{
+		{{Name: "../evil", Typeflag: tar.TypeReg}},
+		{{Name: "a/../../evil", Typeflag: tar.TypeReg}},
+		{{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "../.."}},
+		{{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc"}},
+		{{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "."}, {Name: "link/evil", Typeflag: tar.TypeReg}},
+		{{Name: "hard", Typeflag: tar.TypeLink, Linkname: "../evil"}},
+		// symlinks chained through symlinks already extracted
+		{
+			{Name: "a/b/s", Typeflag: tar.TypeSymlink, Linkname: "../.."},
+			{Name: "a/b/e", Typeflag: tar.TypeSymlink, Linkname: "s/../evil"},
+		},
+		// and through those extracted later
+		{
+			{Name: "a/b/e", Typeflag: tar.TypeSymlink, Linkname: "s/../../../evil"},
+			{Name: "a/b/s", Typeflag: tar.TypeSymlink, Linkname: ".."},
+		},
+		// a hard link moving a symlink to another directory
+		{
+			{Name: "a/b/s", Typeflag: tar.TypeSymlink, Linkname: "../.."},
+			{Name: "s", Typeflag: tar.TypeLink, Linkname: "a/b/s"},
+		},
+	}
+	for _, hdrs := range tests {
+		tree, err := dir.TempTree(dir.TreeSpec{{Path: "out", Mode: os.ModeDir}})
+		if err != nil {
+			t.Fatalf("[archive]TempTree failed: %v", err)
+		}
+		defer tree.Cleanup()
+
+		err = writeTestTar(tree.Path("evil.tar"), hdrs)
+		if err != nil {
+			t.Fatalf("[archive]writing tar failed: %v", err)
+		}
+		err = Extract(tree.Path("evil.tar"), tree.Path("out"), ExtractOptions{})
+		if !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("[archive]Extract test failed for %s, expecting %v, got %v", hdrs[len(hdrs)-1].Name, ErrUnsafePath, err)
+		}
+		if _, err := os.Lstat(tree.Path("evil")); !os.IsNotExist(err) {
+			t.Errorf("[archive]Extract test failed for %s, expecting nothing outside, got %v", hdrs[len(hdrs)-1].Name, err)
+		}
+		for _, name := range []string{"a/b/e", "s"} {
+			if fi, err := os.Lstat(tree.Path("out", name)); err == nil && fi.Mode()&os.ModeSymlink != 0 && !dir.IsWithin(tree.Path("out"), tree.Path("out", name)) {
+				t.Errorf("[archive]Extract test failed, expecting the symlink %s leading out removed, got it", name)
+			}
+		}
+	}
+}
+
+func TestExtractSkipGlobalHeader(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{})
+	if err != nil {
+		t.Fatalf("[archive]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	// like those of `git archive`
+	hdrs := []*tar.Header{
+		{Typeflag: tar.TypeXGlobalHeader, PAXRecords: map[string]string{"comment": "0123abcd"}},
+		{Name: "good", Typeflag: tar.TypeReg},
+	}
+	err = writeTestTar(tree.Path("git.tar"), hdrs)
+	if err != nil {
+		t.Fatalf("[archive]writing tar failed: %v", err)
+	}
+	err = Extract(tree.Path("git.tar"), tree.Path("out"), ExtractOptions{})
+	spec, err1 := (&dir.Tree{Root: tree.Path("out")}).Snapshot()
+	correct := dir.TreeSpec{{Path: "good", Mode: 0644, Content: "good"}}
+	if err != nil || err1 != nil || !reflect.DeepEqual(spec, correct) {
+		t.Errorf("[archive]Extract test failed, expecting %v, got %v, err %v %v", correct, spec, err, err1)
+	}
+}
+
+func TestExtractSkipUnsafe(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{})
+	if err != nil {
+		t.Fatalf("[archive]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	f, err := os.Create(tree.Path("evil.zip"))
+	if err != nil {
+		t.Fatalf("[archive]Create failed: %v", err)
+	}
+	zw := zip.NewWriter(f)
+	for _, name := range []string{"../evil", "/abs/good", "good"} {
+		w, _ := zw.Create(name)
+		w.Write([]byte(name))
+	}
+	zw.Close()
+	f.Close()
+
+	err = Extract(tree.Path("evil.zip"), tree.Path("out"), ExtractOptions{SkipUnsafe: true})
+	if err != nil {
+		t.Fatalf("[archive]Extract test failed, expecting nil, got %v", err)
+	}
+	spec, err := (&dir.Tree{Root: tree.Path("out")}).Snapshot()
+	correct := dir.TreeSpec{
+		{Path: "abs", Mode: os.ModeDir | 0755},
+		{Path: "abs/good", Mode: 0666, Content: "/abs/good"},
+		{Path: "good", Mode: 0666, Content: "good"},
+	}
+	if err != nil || !reflect.DeepEqual(spec, correct) {
+		t.Errorf("[archive]Extract test failed, expecting %v, got %v, err %v", correct, spec, err)
+	}
+}
+
+func TestExtractIncludes(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{})
+	if err != nil {
+		t.Fatalf("[archive]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	hdrs := []*tar.Header{
+		{Name: "app/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "app/bin/app", Typeflag: tar.TypeReg},
+		{Name: "app/bin/tool", Typeflag: tar.TypeLink, Linkname: "app/bin/app"},
+		{Name: "app/doc/README", Typeflag: tar.TypeReg},
+		{Name: "app/doc/NEWS", Typeflag: tar.TypeReg},
+	}
+	err = writeTestTar(tree.Path("app.tar"), hdrs)
+	if err != nil {
+		t.Fatalf("[archive]writing tar failed: %v", err)
+	}
+	err = Extract(tree.Path("app.tar"), tree.Path("out"), ExtractOptions{Includes: []string{"app/bin", "**/README"}, StripComponents: 1})
+	if err != nil {
+		t.Fatalf("[archive]Extract test failed, expecting nil, got %v", err)
+	}
+
+	spec, err := (&dir.Tree{Root: tree.Path("out")}).Snapshot()
+	correct := dir.TreeSpec{
+		{Path: "bin", Mode: os.ModeDir | 0755},
+		{Path: "bin/app", Mode: 0644, Content: "app/bin/app"},
+		{Path: "bin/tool", Mode: 0644, Content: "app/bin/app"},
+		{Path: "doc", Mode: os.ModeDir | 0755},
+		{Path: "doc/README", Mode: 0644, Content: "app/doc/README"},
+	}
+	if err != nil || !reflect.DeepEqual(spec, correct) {
+		t.Errorf("[archive]Extract test failed, expecting %v, got %v, err %v", correct, spec, err)
+	}
+}
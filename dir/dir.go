@@ -6,32 +6,30 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strings"
+	"syscall"
 
-	"github.com/marguerite/go-stdlib/extglob"
 	"github.com/marguerite/go-stdlib/internal"
 	"github.com/marguerite/go-stdlib/slice"
+	"github.com/marguerite/go-stdlib/vfs"
 )
 
 // FollowSymlink follows the path of the symlink recursively and finds out the target it finally points to.
+// at most MaxSymlinkHops symlinks are followed. see Realpath to resolve every component.
 func FollowSymlink(path string) (link string, err error) {
-	link, err = os.Readlink(path)
-	if err != nil {
-		return link, err
-	}
-	if !filepath.IsAbs(link) {
-		link, err = filepath.Abs(filepath.Join(filepath.Dir(path), link))
-		if err != nil {
-			return link, err
-		}
-	}
-	f, err := os.Stat(link)
+	return FollowSymlinkFS(vfs.OS, path)
+}
+
+// FollowSymlinkFS FollowSymlink in fsys
+func FollowSymlinkFS(fsys vfs.FS, path string) (link string, err error) {
+	chain, err := linkChain(fsys, path)
 	if err != nil {
-		return link, err
+		return "", err
 	}
-	if f.Mode()&os.ModeSymlink != 0 {
-		return FollowSymlink(link)
+	if len(chain) < 2 {
+		return "", &os.PathError{Op: "readlink", Path: path, Err: syscall.EINVAL}
 	}
-	return link, nil
+	return vfs.Abs(fsys, chain[len(chain)-1])
 }
 
 // Ls get the file list of directory
@@ -39,15 +37,27 @@ func FollowSymlink(path string) (link string, err error) {
 // recursive: whether to recursively list the second level file list
 // kind: if set, will only list the direcories
 func Ls(directory string, symlink, recursive bool, kind ...string) (files []string, err error) {
-	directories, err := extglob.Expand(internal.Str2bytes(directory))
+	return LsFS(vfs.OS, directory, symlink, recursive, kind...)
+}
+
+// LsFS Ls in fsys
+func LsFS(fsys vfs.FS, directory string, symlink, recursive bool, kind ...string) (files []string, err error) {
+	directories, err := vfs.Expand(fsys, directory)
 	if err != nil {
 		return files, err
 	}
 
 	for _, v := range directories {
-		f, _ := os.Open(v)
+		f, err := fsys.Open(v)
+		if err != nil {
+			return files, err
+		}
 
-		i, _ := f.Stat()
+		i, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return files, err
+		}
 
 		if i.Mode()&os.ModeSymlink != 0 {
 			if !symlink {
@@ -56,14 +66,13 @@ func Ls(directory string, symlink, recursive bool, kind ...string) (files []stri
 				continue
 			}
 			// redirect f to actual file
-			link, err := FollowSymlink(v)
+			link, err := FollowSymlinkFS(fsys, v)
 			f.Close()
 			if err != nil {
 				return files, err
 			}
-			f, err = os.Open(link)
+			f, err = fsys.Open(link)
 			if err != nil {
-				f.Close()
 				return files, err
 			}
 		}
@@ -85,7 +94,7 @@ func Ls(directory string, symlink, recursive bool, kind ...string) (files []stri
 				}
 
 				if recursive && j.IsDir() {
-					subfiles, err := Ls(path, symlink, recursive, kind...)
+					subfiles, err := LsFS(fsys, path, symlink, recursive, kind...)
 					if err != nil {
 						f.Close()
 						return files, err
@@ -111,14 +120,20 @@ func Ls(directory string, symlink, recursive bool, kind ...string) (files []stri
 	return files, nil
 }
 
-// MkdirP create directories for path
+// MkdirP create directories for path, returns os.ErrExist if path already exists.
+// see MkdirPWithOptions for `mkdir -p` semantics.
 func MkdirP(path string) error {
-	_, err := os.Stat(path)
+	return MkdirPFS(vfs.OS, path)
+}
+
+// MkdirPFS MkdirP in fsys
+func MkdirPFS(fsys vfs.FS, path string) error {
+	_, err := fsys.Stat(path)
 	if err == nil {
 		return os.ErrExist
 	}
 	if os.IsNotExist(err) {
-		err = os.MkdirAll(path, os.ModePerm)
+		err = fsys.MkdirAll(path, os.ModePerm)
 		if err != nil {
 			return err
 		}
@@ -127,9 +142,101 @@ func MkdirP(path string) error {
 	return err
 }
 
+// MkdirOptions options for MkdirPWithOptions, like Ruby's FileUtils.mkdir_p
+// Mode: permission of each created component, zero means os.ModePerm
+// NoUmask: chmod each created component to Mode so umask is ignored
+// Owner/Group: name or numeric id to chown each created component to, empty to keep
+type MkdirOptions struct {
+	Mode    os.FileMode
+	NoUmask bool
+	Owner   string
+	Group   string
+}
+
+// MkdirPWithOptions create directories for path like `mkdir -p`.
+// an existing directory is not an error, an existing non-directory is.
+// it returns the components actually created, from top to bottom.
+func MkdirPWithOptions(path string, opts MkdirOptions) (created []string, err error) {
+	mode := opts.Mode
+	if mode == 0 {
+		mode = os.ModePerm
+	}
+
+	uid, gid, err := internal.LookupOwner(opts.Owner, opts.Group)
+	if err != nil {
+		return created, err
+	}
+
+	// find the missing components from bottom to top
+	var missing []string
+	p := filepath.Clean(path)
+	for {
+		fi, err := os.Stat(p)
+		if err == nil {
+			if !fi.IsDir() {
+				return created, &os.PathError{Op: "mkdir", Path: p, Err: syscall.ENOTDIR}
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return created, err
+		}
+		missing = append(missing, p)
+		parent := filepath.Dir(p)
+		if parent == p {
+			break
+		}
+		p = parent
+	}
+
+	for i := len(missing) - 1; i >= 0; i-- {
+		p := missing[i]
+		err := os.Mkdir(p, mode)
+		if err != nil {
+			// created by someone else in the meantime
+			if fi, err1 := os.Stat(p); err1 == nil && fi.IsDir() {
+				continue
+			}
+			return created, err
+		}
+		created = append(created, p)
+
+		if opts.NoUmask {
+			err = os.Chmod(p, mode)
+			if err != nil {
+				return created, err
+			}
+		}
+
+		if uid != -1 || gid != -1 {
+			err = os.Chown(p, uid, gid)
+			if err != nil {
+				return created, err
+			}
+		}
+	}
+
+	return created, nil
+}
+
 // Glob glob actual files via the pattern, pattern can be *regexp.Regexp or string
 // when *regexp.Regexp is used, base is a must.
+// a Confinement anywhere in opts confines the matches to base, which is then a must too.
 func Glob(patt interface{}, opts ...interface{}) ([]string, error) {
+	return GlobFS(vfs.OS, patt, opts...)
+}
+
+// GlobFS Glob in fsys
+func GlobFS(fsys vfs.FS, patt interface{}, opts ...interface{}) ([]string, error) {
+	var confine Confinement
+	for i, v := range opts {
+		if val, ok := v.(Confinement); ok {
+			confine = val
+			opts = append(opts[:i:i], opts[i+1:]...)
+			break
+		}
+	}
+
 	if len(opts) > 2 {
 		return []string{}, fmt.Errorf("opts just have two values: base and exclusion")
 	}
@@ -140,10 +247,13 @@ func Glob(patt interface{}, opts ...interface{}) ([]string, error) {
 			base = val
 		}
 	}
+	if confine != NoConfinement && len(base) == 0 {
+		return []string{}, fmt.Errorf("confinement needs a base")
+	}
 
 	switch val := patt.(type) {
 	case *regexp.Regexp:
-		matches, err := Ls(base, true, true)
+		matches, err := LsFS(fsys, base, true, true)
 		if err != nil {
 			return matches, err
 		}
@@ -161,19 +271,27 @@ func Glob(patt interface{}, opts ...interface{}) ([]string, error) {
 				files = append(files, v)
 			}
 		}
-		return files, nil
+		return confined(fsys, base, files, confine)
 	case string:
 		// string match
+		val, err := confinePattern(val, confine)
+		if err != nil {
+			return []string{}, err
+		}
 		if len(base) > 0 {
 			val = filepath.Join(base, val)
 		}
-		matches, err := extglob.Expand(internal.Str2bytes(val))
+		matches, err := vfs.Expand(fsys, val)
 		if err != nil {
 			return matches, err
 		}
 		if len(opts) > 1 {
 			if val1, ok := opts[1].(string); ok {
-				m, err := extglob.Expand(internal.Str2bytes(filepath.Join(base, val1)))
+				val1, err = confinePattern(val1, confine)
+				if err != nil {
+					return matches, err
+				}
+				m, err := vfs.Expand(fsys, filepath.Join(base, val1))
 				if err != nil {
 					return matches, err
 				}
@@ -183,7 +301,41 @@ func Glob(patt interface{}, opts ...interface{}) ([]string, error) {
 				}
 			}
 		}
-		return matches, nil
+		return confined(fsys, base, matches, confine)
 	}
 	return []string{}, nil
 }
+
+// confinePattern check the pattern relative to base does not lead out of it with '..',
+// or clamp it so it does not
+func confinePattern(pattern string, confine Confinement) (string, error) {
+	switch confine {
+	case RejectEscapes:
+		p := filepath.Clean(pattern)
+		if p == ".." || strings.HasPrefix(p, ".."+string(os.PathSeparator)) {
+			return pattern, &os.PathError{Op: "glob", Path: pattern, Err: ErrEscapesBase}
+		}
+	case ClampEscapes:
+		// '..' stops at the root directory
+		return filepath.Join(string(os.PathSeparator), pattern), nil
+	}
+	return pattern, nil
+}
+
+// confined check the matches are within base, symlinks resolved, or drop those which are not
+func confined(fsys vfs.FS, base string, matches []string, confine Confinement) ([]string, error) {
+	if confine == NoConfinement {
+		return matches, nil
+	}
+	kept := matches[:0]
+	for _, v := range matches {
+		if within(fsys, base, v) {
+			kept = append(kept, v)
+			continue
+		}
+		if confine == RejectEscapes {
+			return []string{}, &os.PathError{Op: "glob", Path: v, Err: ErrEscapesBase}
+		}
+	}
+	return kept, nil
+}
@@ -1,28 +1,46 @@
 package dir
 
 import (
+	"errors"
 	"os"
-	"path/filepath"
 	"reflect"
 	"regexp"
 	"testing"
 
 	"github.com/marguerite/go-stdlib/slice"
+	"github.com/marguerite/go-stdlib/vfs"
 )
 
+var spec = TreeSpec{
+	{Path: "dir.go", Content: "package dir"},
+	{Path: "dir_test.go", Content: "package dir"},
+	{Path: "sub/dir_sub.go", Content: "package sub"},
+	{Path: "sub/README"},
+}
+
 func TestLs(t *testing.T) {
-	cwd, _ := os.Getwd()
-	correct := []string{filepath.Join(cwd, "dir.go"), filepath.Join(cwd, "dir_test.go")}
-	if files, err := Ls(cwd, true, true); !reflect.DeepEqual(files, correct) || err != nil {
+	tree, err := TempTree(spec)
+	if err != nil {
+		t.Fatalf("[dir]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	correct := []string{tree.Path("dir.go"), tree.Path("dir_test.go"), tree.Path("sub"), tree.Path("sub/README"), tree.Path("sub/dir_sub.go")}
+	if files, err := Ls(tree.Root, true, true); !reflect.DeepEqual(files, correct) || err != nil {
 		t.Errorf("[dir]Ls test failed, expecting %s, got %s, err %v", correct, files, err)
 	}
 }
 
 func TestGlobString(t *testing.T) {
-	cwd, _ := os.Getwd()
-	patt := filepath.Join(filepath.Dir(cwd), "**", "dir*.go")
+	tree, err := TempTree(spec)
+	if err != nil {
+		t.Fatalf("[dir]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	patt := tree.Path("**", "dir*.go")
 	t.Logf("[dir]Glob pattern is %s", patt)
-	correct := filepath.Join(filepath.Dir(cwd), "dir", "dir_test.go")
+	correct := tree.Path("sub", "dir_sub.go")
 	result, err := Glob(patt)
 	if err != nil {
 		t.Errorf("[di]: Glob test failed with %s", err.Error())
@@ -37,10 +55,15 @@ func TestGlobString(t *testing.T) {
 }
 
 func TestGlobRegex(t *testing.T) {
-	cwd, _ := os.Getwd()
+	tree, err := TempTree(spec)
+	if err != nil {
+		t.Fatalf("[dir]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
 	re := regexp.MustCompile(`dir.*\.go`)
-	correct := filepath.Join(cwd, "dir_test.go")
-	result, err := Glob(re, cwd)
+	correct := tree.Path("dir_test.go")
+	result, err := Glob(re, tree.Root)
 	if err != nil {
 		t.Errorf("[di]: Glob test failed with %s", err.Error())
 	}
@@ -54,11 +77,16 @@ func TestGlobRegex(t *testing.T) {
 }
 
 func TestGlobRegexWithExclusion(t *testing.T) {
-	cwd, _ := os.Getwd()
+	tree, err := TempTree(spec)
+	if err != nil {
+		t.Fatalf("[dir]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
 	re := regexp.MustCompile(`dir.*\.go`)
 	re1 := regexp.MustCompile(`dir_test\.go`)
-	correct := filepath.Join(cwd, "dir.go")
-	result, err := Glob(re, cwd, re1)
+	correct := tree.Path("dir.go")
+	result, err := Glob(re, tree.Root, re1)
 	if err != nil {
 		t.Errorf("[di]: Glob test failed with %s", err.Error())
 	}
@@ -66,7 +94,99 @@ func TestGlobRegexWithExclusion(t *testing.T) {
 		if ok, err := slice.Contains(result, correct); !ok || err != nil {
 			t.Errorf("[dir]: Glob test failed, expecting %s, got %s", correct, result)
 		}
+		if ok, _ := slice.Contains(result, tree.Path("dir_test.go")); ok {
+			t.Errorf("[dir]: Glob test failed, dir_test.go should be excluded, got %s", result)
+		}
 	} else {
 		t.Errorf("[dir]: Glob test failed, expecting %s, got empty", correct)
 	}
 }
+
+func TestMkdirPWithOptions(t *testing.T) {
+	tree, err := TempTree(TreeSpec{{Path: "a/file", Content: "x"}})
+	if err != nil {
+		t.Fatalf("[dir]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	created, err := MkdirPWithOptions(tree.Path("a/b/c"), MkdirOptions{Mode: 0700, NoUmask: true})
+	correct := []string{tree.Path("a/b"), tree.Path("a/b/c")}
+	if err != nil || !reflect.DeepEqual(created, correct) {
+		t.Errorf("[dir]MkdirPWithOptions test failed, expecting %s, got %s, err %v", correct, created, err)
+	}
+	for _, p := range correct {
+		if fi, err := os.Stat(p); err != nil || fi.Mode().Perm() != 0700 {
+			t.Errorf("[dir]MkdirPWithOptions test failed, expecting %s with mode 0700, got %v, err %v", p, fi.Mode(), err)
+		}
+	}
+
+	// idempotent like mkdir -p
+	created, err = MkdirPWithOptions(tree.Path("a/b/c"), MkdirOptions{})
+	if err != nil || len(created) != 0 {
+		t.Errorf("[dir]MkdirPWithOptions test failed, expecting nothing created, got %s, err %v", created, err)
+	}
+
+	_, err = MkdirPWithOptions(tree.Path("a/file/d"), MkdirOptions{})
+	if err == nil {
+		t.Error("[dir]MkdirPWithOptions test failed, expecting error when a component is a file, got nil")
+	}
+}
+
+// memTree spec in a vfs.MemFS under /base, with a symlink leading out of it
+func memTree() *vfs.MemFS {
+	m := vfs.NewMemFS()
+	m.MkdirAll("/base/sub", 0755)
+	m.MkdirAll("/secret", 0755)
+	for _, v := range []string{"/base/dir.go", "/base/dir_test.go", "/base/sub/dir_sub.go", "/base/sub/README", "/secret/key.go"} {
+		f, _ := m.Create(v)
+		f.Close()
+	}
+	m.Symlink("/secret", "/base/out")
+	return m
+}
+
+func TestLsFS(t *testing.T) {
+	correct := []string{"/base/sub/README", "/base/sub/dir_sub.go"}
+	if files, err := LsFS(memTree(), "/base/sub", true, true); !reflect.DeepEqual(files, correct) || err != nil {
+		t.Errorf("[dir]LsFS test failed, expecting %s, got %s, err %v", correct, files, err)
+	}
+	// symlinks to directories are not directories here, like in Ls
+	correct = []string{"/base/sub"}
+	if files, err := LsFS(memTree(), "/base", false, false, "dir"); !reflect.DeepEqual(files, correct) || err != nil {
+		t.Errorf("[dir]LsFS test failed, expecting %s, got %s, err %v", correct, files, err)
+	}
+}
+
+func TestGlobFS(t *testing.T) {
+	m := memTree()
+	correct := []string{"/base/dir.go"}
+	if files, err := GlobFS(m, "dir*.go", "/base", "*_test.go"); !reflect.DeepEqual(files, correct) || err != nil {
+		t.Errorf("[dir]GlobFS test failed, expecting %s, got %s, err %v", correct, files, err)
+	}
+
+	correct = []string{"/base/sub/dir_sub.go"}
+	if files, err := GlobFS(m, regexp.MustCompile(`sub/.*\.go$`), "/base"); !reflect.DeepEqual(files, correct) || err != nil {
+		t.Errorf("[dir]GlobFS test failed, expecting %s, got %s, err %v", correct, files, err)
+	}
+
+	correct = []string{"/base/sub"}
+	if files, err := GlobFS(m, "@(out|sub)", "/base", ClampEscapes); !reflect.DeepEqual(files, correct) || err != nil {
+		t.Errorf("[dir]GlobFS test failed, expecting %s, got %s, err %v", correct, files, err)
+	}
+	if _, err := GlobFS(m, "@(out|sub)", "/base", RejectEscapes); !errors.Is(err, ErrEscapesBase) {
+		t.Errorf("[dir]GlobFS test failed, expecting ErrEscapesBase, got %v", err)
+	}
+}
+
+func TestMkdirPFS(t *testing.T) {
+	m := memTree()
+	if err := MkdirPFS(m, "/base/a/b"); err != nil {
+		t.Errorf("[dir]MkdirPFS test failed: %v", err)
+	}
+	if fi, err := m.Stat("/base/a/b"); err != nil || !fi.IsDir() {
+		t.Errorf("[dir]MkdirPFS test failed, expecting /base/a/b created, got %v, err %v", fi, err)
+	}
+	if err := MkdirPFS(m, "/base/sub"); err != os.ErrExist {
+		t.Errorf("[dir]MkdirPFS test failed, expecting os.ErrExist, got %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package dir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LockFileName the lock file created inside a directory passed to Lock
+const LockFileName = ".lock"
+
+var (
+	ErrLocked         = errors.New("lock is held by another process")
+	ErrAlreadyRunning = errors.New("another instance is already running")
+)
+
+// LockMode shared or exclusive advisory lock
+type LockMode int
+
+const (
+	// Exclusive only one holder at a time, like `flock -x`
+	Exclusive LockMode = iota
+	// Shared many holders at a time but no exclusive one, like `flock -s`
+	Shared
+)
+
+// LockOptions options for Lock
+// Mode: Exclusive or Shared
+// NonBlocking: return ErrLocked at once instead of waiting, like `flock -n`
+// Context: stop waiting when the context is done, nil waits forever
+// Fcntl: use fcntl(2) record locks instead of flock(2), eg: on NFS.
+// note fcntl locks are per process, locks taken by the same process never conflict
+// and closing any descriptor of the file releases them.
+type LockOptions struct {
+	Mode        LockMode
+	NonBlocking bool
+	Context     context.Context
+	Fcntl       bool
+}
+
+// FileLock an acquired advisory lock
+type FileLock struct {
+	Path  string
+	f     *os.File
+	fcntl bool
+}
+
+// Lock acquire an advisory lock on path. if path is a directory, the lock is taken
+// on LockFileName inside it, otherwise on path itself, which is created with its
+// parent directories if missing.
+func Lock(path string, opts LockOptions) (*FileLock, error) {
+	p := path
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		p = filepath.Join(path, LockFileName)
+	} else {
+		_, err := MkdirPWithOptions(filepath.Dir(p), MkdirOptions{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil && os.IsPermission(err) && opts.Mode == Shared {
+		// a shared lock can do with a read-only lock file
+		f, err = os.Open(p)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = acquire(f, opts)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &FileLock{Path: p, f: f, fcntl: opts.Fcntl}, nil
+}
+
+func acquire(f *os.File, opts LockOptions) error {
+	if opts.NonBlocking || opts.Context == nil {
+		return lockFile(f, opts.Mode, opts.NonBlocking, opts.Fcntl)
+	}
+
+	// a blocking lock can not be interrupted, so poll with backoff instead
+	delay := 10 * time.Millisecond
+	for {
+		err := lockFile(f, opts.Mode, true, opts.Fcntl)
+		if err != ErrLocked {
+			return err
+		}
+		select {
+		case <-opts.Context.Done():
+			return opts.Context.Err()
+		case <-time.After(delay):
+		}
+		if delay < 500*time.Millisecond {
+			delay *= 2
+		}
+	}
+}
+
+// Unlock release the lock, the lock file is kept
+func (l *FileLock) Unlock() error {
+	err := unlockFile(l.f, l.fcntl)
+	err1 := l.f.Close()
+	if err != nil {
+		return err
+	}
+	return err1
+}
+
+// Pidfile a pid file guarding a single running instance
+type Pidfile struct {
+	lock *FileLock
+}
+
+// SingleInstance make sure only one process runs with the pid file at path.
+// the pid file is locked exclusively for the life of the process and holds its pid.
+// a pid left behind by a process that no longer exists is stale and taken over,
+// otherwise the error wraps ErrAlreadyRunning. the pid is only checked for
+// processes not using the lock, so if a crashed instance left its pid behind
+// and the system reused it for an unrelated process, that is reported as
+// ErrAlreadyRunning too, until the pid file is emptied or removed.
+func SingleInstance(path string) (*Pidfile, error) {
+	lock, err := Lock(path, LockOptions{NonBlocking: true})
+	if err != nil {
+		if err == ErrLocked {
+			pid, _ := readPid(path)
+			return nil, fmt.Errorf("%w: pid %d holds %s", ErrAlreadyRunning, pid, path)
+		}
+		return nil, err
+	}
+
+	// the lock is ours, but the pid may come from a process not using locks
+	if pid, err := readPid(path); err == nil && pid != os.Getpid() && processAlive(pid) {
+		lock.Unlock()
+		return nil, fmt.Errorf("%w: pid %d in %s", ErrAlreadyRunning, pid, path)
+	}
+
+	err = lock.f.Truncate(0)
+	if err == nil {
+		_, err = lock.f.Seek(0, io.SeekStart)
+	}
+	if err == nil {
+		_, err = lock.f.WriteString(strconv.Itoa(os.Getpid()) + "\n")
+	}
+	if err == nil {
+		err = lock.f.Sync()
+	}
+	if err != nil {
+		lock.Unlock()
+		return nil, err
+	}
+
+	return &Pidfile{lock: lock}, nil
+}
+
+// Path the path of the pid file
+func (p *Pidfile) Path() string {
+	return p.lock.Path
+}
+
+// Release empty the pid file and release its lock. the file is kept: removing
+// it would let a process that opened it before the removal lock the unlinked
+// file while another creates and locks a new one.
+func (p *Pidfile) Release() error {
+	err := p.lock.f.Truncate(0)
+	err1 := p.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	return err1
+}
+
+func readPid(path string) (int, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
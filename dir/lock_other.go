@@ -0,0 +1,22 @@
+// +build !linux,!freebsd,!openbsd,!netbsd,!darwin
+
+package dir
+
+import (
+	"errors"
+	"os"
+)
+
+var errLockUnsupported = errors.New("file locking is not supported on this system")
+
+func lockFile(f *os.File, mode LockMode, nonblock, fcntl bool) error {
+	return errLockUnsupported
+}
+
+func unlockFile(f *os.File, fcntl bool) error {
+	return errLockUnsupported
+}
+
+func processAlive(pid int) bool {
+	return false
+}
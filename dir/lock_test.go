@@ -0,0 +1,103 @@
+// +build linux freebsd openbsd netbsd darwin
+
+package dir
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	tree, err := TempTree(TreeSpec{{Path: "cache", Mode: os.ModeDir}})
+	if err != nil {
+		t.Fatalf("[dir]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	l, err := Lock(tree.Path("cache"), LockOptions{})
+	if err != nil || l.Path != tree.Path("cache", LockFileName) {
+		t.Fatalf("[dir]Lock test failed, expecting lock on %s, got %v, err %v", tree.Path("cache", LockFileName), l, err)
+	}
+
+	// flock locks conflict between open files even in the same process
+	_, err = Lock(tree.Path("cache"), LockOptions{NonBlocking: true})
+	if err != ErrLocked {
+		t.Errorf("[dir]Lock test failed, expecting ErrLocked, got %v", err)
+	}
+	_, err = Lock(tree.Path("cache"), LockOptions{Mode: Shared, NonBlocking: true})
+	if err != ErrLocked {
+		t.Errorf("[dir]Lock shared test failed, expecting ErrLocked, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = Lock(tree.Path("cache"), LockOptions{Context: ctx})
+	if err != context.DeadlineExceeded {
+		t.Errorf("[dir]Lock timeout test failed, expecting context.DeadlineExceeded, got %v", err)
+	}
+
+	if err := l.Unlock(); err != nil {
+		t.Errorf("[dir]Unlock test failed, expecting nil, got %v", err)
+	}
+
+	s1, err := Lock(tree.Path("cache"), LockOptions{Mode: Shared, NonBlocking: true})
+	if err != nil {
+		t.Fatalf("[dir]Lock shared test failed, expecting nil, got %v", err)
+	}
+	defer s1.Unlock()
+	s2, err := Lock(tree.Path("cache"), LockOptions{Mode: Shared, NonBlocking: true})
+	if err != nil {
+		t.Fatalf("[dir]Lock shared test failed, expecting two shared locks, got %v", err)
+	}
+	defer s2.Unlock()
+}
+
+func TestSingleInstance(t *testing.T) {
+	tree, err := TempTree(nil)
+	if err != nil {
+		t.Fatalf("[dir]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	// a pid that surely no longer exists
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("[dir]SingleInstance test skipped, can not run true: %v", err)
+	}
+	pidfile := tree.Path("run/app.pid")
+	os.MkdirAll(tree.Path("run"), 0755)
+	ioutil.WriteFile(pidfile, []byte(strconv.Itoa(cmd.Process.Pid)), 0644)
+
+	p, err := SingleInstance(pidfile)
+	if err != nil {
+		t.Fatalf("[dir]SingleInstance stale pid test failed, expecting nil, got %v", err)
+	}
+
+	_, err = SingleInstance(pidfile)
+	if !errors.Is(err, ErrAlreadyRunning) {
+		t.Errorf("[dir]SingleInstance test failed, expecting ErrAlreadyRunning, got %v", err)
+	}
+
+	if pid, err := readPid(pidfile); err != nil || pid != os.Getpid() {
+		t.Errorf("[dir]SingleInstance test failed, expecting pid %d, got %d, err %v", os.Getpid(), pid, err)
+	}
+
+	if err := p.Release(); err != nil {
+		t.Errorf("[dir]Pidfile.Release test failed, expecting nil, got %v", err)
+	}
+	if b, err := ioutil.ReadFile(pidfile); err != nil || len(b) > 0 {
+		t.Errorf("[dir]Pidfile.Release test failed, expecting %s emptied, got %q, err %v", pidfile, b, err)
+	}
+
+	p, err = SingleInstance(pidfile)
+	if err != nil {
+		t.Fatalf("[dir]SingleInstance after Release test failed, expecting nil, got %v", err)
+	}
+	p.Release()
+}
@@ -0,0 +1,71 @@
+// +build linux freebsd openbsd netbsd darwin
+
+package dir
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func lockFile(f *os.File, mode LockMode, nonblock, fcntl bool) error {
+	var err error
+
+	for {
+		if fcntl {
+			lk := unix.Flock_t{Type: unix.F_WRLCK, Whence: io.SeekStart}
+			if mode == Shared {
+				lk.Type = unix.F_RDLCK
+			}
+			cmd := unix.F_SETLKW
+			if nonblock {
+				cmd = unix.F_SETLK
+			}
+			err = unix.FcntlFlock(f.Fd(), cmd, &lk)
+		} else {
+			how := unix.LOCK_EX
+			if mode == Shared {
+				how = unix.LOCK_SH
+			}
+			if nonblock {
+				how |= unix.LOCK_NB
+			}
+			err = unix.Flock(int(f.Fd()), how)
+		}
+		if err != unix.EINTR {
+			break
+		}
+	}
+
+	switch err {
+	case nil:
+		return nil
+	case unix.EWOULDBLOCK, unix.EACCES:
+		return ErrLocked
+	default:
+		return &os.PathError{Op: "lock", Path: f.Name(), Err: err}
+	}
+}
+
+func unlockFile(f *os.File, fcntl bool) error {
+	var err error
+	if fcntl {
+		lk := unix.Flock_t{Type: unix.F_UNLCK, Whence: io.SeekStart}
+		err = unix.FcntlFlock(f.Fd(), unix.F_SETLK, &lk)
+	} else {
+		err = unix.Flock(int(f.Fd()), unix.LOCK_UN)
+	}
+	if err != nil {
+		return &os.PathError{Op: "unlock", Path: f.Name(), Err: err}
+	}
+	return nil
+}
+
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := unix.Kill(pid, 0)
+	return err == nil || err == unix.EPERM
+}
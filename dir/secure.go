@@ -0,0 +1,119 @@
+package dir
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/marguerite/go-stdlib/vfs"
+)
+
+// ErrEscapesBase a path leads out of the base directory it is confined to
+var ErrEscapesBase = errors.New("path escapes the base directory")
+
+// Confinement how paths leading out of a base directory are handled
+type Confinement int
+
+const (
+	// NoConfinement paths may lead anywhere
+	NoConfinement Confinement = iota
+	// RejectEscapes fail with ErrEscapesBase
+	RejectEscapes
+	// ClampEscapes keep paths inside, '..' stops at the base directory like
+	// at the root directory, and matches leading out through symlinks are dropped
+	ClampEscapes
+)
+
+// SecureJoin join unsafePath to root like filepath.Join, but resolve the symlinks
+// of every component as if root was the root directory: '..' stops at root and
+// absolute symlink targets start from it, so the result never leads out of root.
+// missing components are kept as they are. the result is only safe as long as
+// nobody else changes the symlinks under root.
+func SecureJoin(root, unsafePath string) (string, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+
+	// resolved is relative to root, "" for root itself
+	var resolved string
+	remaining := splitPath(unsafePath[len(filepath.VolumeName(unsafePath)):])
+	hops := 0
+
+	for len(remaining) > 0 {
+		comp := remaining[0]
+		remaining = remaining[1:]
+
+		switch comp {
+		case "", ".":
+			continue
+		case "..":
+			resolved = parentOf(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, comp)
+		fi, err := os.Lstat(filepath.Join(root, next))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return "", err
+			}
+			resolved = next
+			continue
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		hops++
+		if hops > MaxSymlinkHops {
+			return "", &os.PathError{Op: "securejoin", Path: unsafePath, Err: syscall.ELOOP}
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = ""
+			target = target[len(filepath.VolumeName(target)):]
+		}
+		remaining = append(splitPath(target), remaining...)
+	}
+
+	return filepath.Join(root, resolved), nil
+}
+
+// parentOf the parent of the relative path p, "" at the top
+func parentOf(p string) string {
+	i := strings.LastIndexByte(p, os.PathSeparator)
+	if i < 0 {
+		return ""
+	}
+	return p[:i]
+}
+
+// IsWithin whether path is root or inside root once the symlinks of both are
+// resolved, missing components are taken as they are. a path that can not be
+// resolved, eg: through a symlink loop, is not within root.
+func IsWithin(root, path string) bool {
+	return within(vfs.OS, root, path)
+}
+
+func within(fsys vfs.FS, root, path string) bool {
+	root, err := realpath(fsys, root, CanonicalizeMissing)
+	if err != nil {
+		return false
+	}
+	path, err = realpath(fsys, path, CanonicalizeMissing)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator)))
+}
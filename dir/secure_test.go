@@ -0,0 +1,97 @@
+package dir
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+var secureSpec = TreeSpec{
+	{Path: "root/etc/passwd", Content: "inside"},
+	{Path: "root/abs", Mode: os.ModeSymlink, Target: "/etc"},
+	{Path: "root/up", Mode: os.ModeSymlink, Target: "../.."},
+	{Path: "root/sub/dot", Mode: os.ModeSymlink, Target: "."},
+	{Path: "root/loop", Mode: os.ModeSymlink, Target: "loop"},
+	{Path: "root/out", Mode: os.ModeSymlink, Target: "../secret"},
+	{Path: "secret", Content: "outside"},
+}
+
+func TestSecureJoin(t *testing.T) {
+	tree, err := TempTree(secureSpec)
+	if err != nil {
+		t.Fatalf("[dir]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	root := tree.Path("root")
+	tests := map[string]string{
+		"etc/passwd":          tree.Path("root/etc/passwd"),
+		"../../etc/passwd":    tree.Path("root/etc/passwd"),
+		"/etc/passwd":         tree.Path("root/etc/passwd"),
+		"abs/passwd":          tree.Path("root/etc/passwd"),
+		"up/etc/passwd":       tree.Path("root/etc/passwd"),
+		"sub/dot/dot/missing": tree.Path("root/sub/missing"),
+		"out":                 tree.Path("root/secret"),
+	}
+	for p, correct := range tests {
+		if got, err := SecureJoin(root, p); err != nil || got != correct {
+			t.Errorf("[dir]SecureJoin test failed for %s, expecting %s, got %s, err %v", p, correct, got, err)
+		}
+	}
+	if _, err := SecureJoin(root, "loop/x"); err == nil {
+		t.Errorf("[dir]SecureJoin test failed, expecting ELOOP, got nil")
+	}
+}
+
+func TestIsWithin(t *testing.T) {
+	tree, err := TempTree(secureSpec)
+	if err != nil {
+		t.Fatalf("[dir]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	tests := map[string]bool{
+		"root":             true,
+		"root/etc/passwd":  true,
+		"root/sub/dot":     true,
+		"root/missing/x":   true,
+		"root/../secret":   false,
+		"root/out":         false,
+		"root/up/anything": false,
+		"root/loop":        false,
+	}
+	for p, correct := range tests {
+		if got := IsWithin(tree.Path("root"), tree.Root+"/"+p); got != correct {
+			t.Errorf("[dir]IsWithin test failed for %s, expecting %v, got %v", p, correct, got)
+		}
+	}
+}
+
+func TestGlobConfinement(t *testing.T) {
+	tree, err := TempTree(secureSpec)
+	if err != nil {
+		t.Fatalf("[dir]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	root := tree.Path("root")
+	_, err = Glob("../secre?", root, RejectEscapes)
+	if !errors.Is(err, ErrEscapesBase) {
+		t.Errorf("[dir]Glob test failed, expecting %v, got %v", ErrEscapesBase, err)
+	}
+	_, err = Glob("o*", root, RejectEscapes)
+	if !errors.Is(err, ErrEscapesBase) {
+		t.Errorf("[dir]Glob test failed, expecting %v for a symlink leading out, got %v", ErrEscapesBase, err)
+	}
+
+	matches, err := Glob("../../e*/passwd", root, ClampEscapes)
+	if correct := []string{tree.Path("root/etc/passwd")}; err != nil || !reflect.DeepEqual(matches, correct) {
+		t.Errorf("[dir]Glob test failed, expecting %v, got %v, err %v", correct, matches, err)
+	}
+	matches, err = Glob(regexp.MustCompile(`/(out|etc)$`), root, ClampEscapes)
+	if correct := []string{tree.Path("root/etc")}; err != nil || !reflect.DeepEqual(matches, correct) {
+		t.Errorf("[dir]Glob test failed, expecting %v, got %v, err %v", correct, matches, err)
+	}
+}
@@ -0,0 +1,161 @@
+package dir
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/marguerite/go-stdlib/vfs"
+)
+
+// MaxSymlinkHops the maximum number of symlinks followed while resolving a path,
+// the same as Linux's MAXSYMLINKS. exceeding it returns syscall.ELOOP.
+const MaxSymlinkHops = 40
+
+// RealpathMode how Realpath treats missing components, like readlink's -f, -e and -m
+type RealpathMode int
+
+const (
+	// Canonicalize all but the last component must exist, like `readlink -f`
+	Canonicalize RealpathMode = iota
+	// CanonicalizeExisting all components must exist, like `readlink -e`
+	CanonicalizeExisting
+	// CanonicalizeMissing no component needs to exist, like `readlink -m`
+	CanonicalizeMissing
+)
+
+// Realpath return the canonical absolute path of path, every symlink in every
+// component is resolved and '.', '..' and duplicated separators are removed.
+func Realpath(path string, mode RealpathMode) (string, error) {
+	return realpath(vfs.OS, path, mode)
+}
+
+func realpath(fsys vfs.FS, path string, mode RealpathMode) (string, error) {
+	// filepath.Abs cleans lexically, which is wrong when '..' follows a symlink
+	abs := path
+	if !filepath.IsAbs(path) {
+		wd := string(os.PathSeparator)
+		if fsys == vfs.OS {
+			var err error
+			wd, err = os.Getwd()
+			if err != nil {
+				return "", err
+			}
+		}
+		abs = wd + string(os.PathSeparator) + path
+	}
+
+	vol := filepath.VolumeName(abs)
+	root := vol + string(os.PathSeparator)
+	resolved := root
+	remaining := splitPath(abs[len(vol):])
+	hops := 0
+
+	for len(remaining) > 0 {
+		comp := remaining[0]
+		remaining = remaining[1:]
+
+		switch comp {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, comp)
+		fi, err := fsys.Lstat(next)
+		if err != nil {
+			switch {
+			case mode == CanonicalizeMissing:
+			case mode == Canonicalize && os.IsNotExist(err) && isLast(remaining):
+			default:
+				return "", &os.PathError{Op: "realpath", Path: path, Err: underlying(err)}
+			}
+			resolved = next
+			continue
+		}
+
+		if fi.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		hops++
+		if hops > MaxSymlinkHops {
+			return "", &os.PathError{Op: "realpath", Path: path, Err: syscall.ELOOP}
+		}
+
+		target, err := fsys.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			vol = filepath.VolumeName(target)
+			resolved = vol + string(os.PathSeparator)
+			target = target[len(vol):]
+		}
+		remaining = append(splitPath(target), remaining...)
+	}
+
+	return resolved, nil
+}
+
+// LinkChain return every hop of the symlink path points to, starting from path itself
+// and ending with the first non-symlink. only the last component is followed, the
+// intermediate components are kept as is. when the chain ends in a missing target,
+// the chain so far is returned along with the error.
+func LinkChain(path string) ([]string, error) {
+	return linkChain(vfs.OS, path)
+}
+
+func linkChain(fsys vfs.FS, path string) ([]string, error) {
+	chain := []string{path}
+	current := path
+
+	for {
+		fi, err := fsys.Lstat(current)
+		if err != nil {
+			return chain, err
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return chain, nil
+		}
+
+		if len(chain) > MaxSymlinkHops {
+			return chain, &os.PathError{Op: "readlink", Path: path, Err: syscall.ELOOP}
+		}
+
+		target, err := fsys.Readlink(current)
+		if err != nil {
+			return chain, err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(current), target)
+		}
+		chain = append(chain, target)
+		current = target
+	}
+}
+
+func splitPath(path string) []string {
+	return strings.Split(filepath.ToSlash(path), "/")
+}
+
+// isLast whether the remaining components are all empty or '.'
+func isLast(remaining []string) bool {
+	for _, v := range remaining {
+		if v != "" && v != "." {
+			return false
+		}
+	}
+	return true
+}
+
+func underlying(err error) error {
+	if e, ok := err.(*os.PathError); ok {
+		return e.Err
+	}
+	return err
+}
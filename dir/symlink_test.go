@@ -0,0 +1,78 @@
+package dir
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+func TestRealpath(t *testing.T) {
+	tree, err := TempTree(TreeSpec{
+		{Path: "real/file", Content: "x"},
+		{Path: "link", Mode: os.ModeSymlink, Target: "real"},
+		{Path: "real/up", Mode: os.ModeSymlink, Target: "../link"},
+		{Path: "loop1", Mode: os.ModeSymlink, Target: "loop2"},
+		{Path: "loop2", Mode: os.ModeSymlink, Target: "loop1"},
+	})
+	if err != nil {
+		t.Fatalf("[dir]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	tests := []struct {
+		path    string
+		mode    RealpathMode
+		correct string
+		fail    bool
+	}{
+		{"link/up/file", Canonicalize, tree.Path("real/file"), false},
+		{"link/./up/../link/file", CanonicalizeExisting, tree.Path("real/file"), false},
+		{"link/missing", Canonicalize, tree.Path("real/missing"), false},
+		{"link/missing", CanonicalizeExisting, "", true},
+		{"link/missing/deeper", Canonicalize, "", true},
+		{"link/missing/deeper/..", CanonicalizeMissing, tree.Path("real/missing"), false},
+	}
+
+	for _, v := range tests {
+		p, err := Realpath(tree.Root+"/"+v.path, v.mode)
+		if (err != nil) != v.fail || p != v.correct {
+			t.Errorf("[dir]Realpath %s mode %d test failed, expecting %s, got %s, err %v", v.path, v.mode, v.correct, p, err)
+		}
+	}
+
+	_, err = Realpath(tree.Path("loop1"), CanonicalizeMissing)
+	if !errors.Is(err, syscall.ELOOP) {
+		t.Errorf("[dir]Realpath loop test failed, expecting ELOOP, got %v", err)
+	}
+}
+
+func TestLinkChain(t *testing.T) {
+	tree, err := TempTree(TreeSpec{
+		{Path: "file", Content: "x"},
+		{Path: "a", Mode: os.ModeSymlink, Target: "b"},
+		{Path: "b", Mode: os.ModeSymlink, Target: "file"},
+		{Path: "self", Mode: os.ModeSymlink, Target: "self"},
+	})
+	if err != nil {
+		t.Fatalf("[dir]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	correct := []string{tree.Path("a"), tree.Path("b"), tree.Path("file")}
+	chain, err := LinkChain(tree.Path("a"))
+	if err != nil || !reflect.DeepEqual(chain, correct) {
+		t.Errorf("[dir]LinkChain test failed, expecting %s, got %s, err %v", correct, chain, err)
+	}
+
+	link, err := FollowSymlink(tree.Path("a"))
+	if err != nil || link != tree.Path("file") {
+		t.Errorf("[dir]FollowSymlink test failed, expecting %s, got %s, err %v", tree.Path("file"), link, err)
+	}
+
+	_, err = FollowSymlink(tree.Path("self"))
+	if !errors.Is(err, syscall.ELOOP) {
+		t.Errorf("[dir]FollowSymlink loop test failed, expecting ELOOP, got %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package dir
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TreeEntry a single node of a directory tree.
+// Path is slash separated and relative to the tree root.
+// Mode carries the file type (os.ModeDir, os.ModeSymlink or none for regular files)
+// and the permission bits. a zero permission means 0644 for files and 0755 for directories.
+// Content is used by regular files, Target by symlinks.
+type TreeEntry struct {
+	Path    string
+	Mode    os.FileMode
+	Content string
+	Target  string
+}
+
+// TreeSpec a declarative description of a directory tree
+type TreeSpec []TreeEntry
+
+// Tree a directory tree materialized under Root
+type Tree struct {
+	Root string
+}
+
+// TempTree materialize spec in a fresh temporary directory.
+// missing parent directories are created with 0755, permissions are applied
+// regardless of umask. call Cleanup to remove the whole tree.
+func TempTree(spec TreeSpec) (*Tree, error) {
+	root, err := ioutil.TempDir("", "go-stdlib-")
+	if err != nil {
+		return nil, err
+	}
+	// TMPDIR itself may be a symlink, eg: /var -> /private/var on darwin
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tree{Root: root}
+	err = t.build(spec)
+	if err != nil {
+		t.Cleanup()
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *Tree) build(spec TreeSpec) error {
+	// directory permissions are applied at last, so read-only directories can still be populated
+	var dirs []TreeEntry
+	// the parent directories not in spec, which MkdirAll creates subject to umask
+	parents := make(map[string]bool)
+
+	for _, e := range spec {
+		p, err := t.abs(e.Path)
+		if err != nil {
+			return err
+		}
+		for d := filepath.Dir(p); d != t.Root; d = filepath.Dir(d) {
+			parents[d] = true
+		}
+
+		err = os.MkdirAll(filepath.Dir(p), 0755)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case e.Mode.IsDir():
+			err = os.MkdirAll(p, 0755)
+			dirs = append(dirs, e)
+		case e.Mode&os.ModeSymlink != 0:
+			err = os.Symlink(filepath.FromSlash(e.Target), p)
+		case e.Mode.IsRegular():
+			err = ioutil.WriteFile(p, []byte(e.Content), 0600)
+			if err == nil {
+				err = os.Chmod(p, perm(e.Mode, 0644))
+			}
+		default:
+			err = fmt.Errorf("tree entry %s has unsupported filemode %v", e.Path, e.Mode)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, e := range dirs {
+		p, _ := t.abs(e.Path)
+		delete(parents, p)
+	}
+	for p := range parents {
+		rel, _ := filepath.Rel(t.Root, p)
+		dirs = append(dirs, TreeEntry{Path: filepath.ToSlash(rel), Mode: os.ModeDir})
+	}
+
+	// deepest first, a read-only parent should not block its children
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].Path > dirs[j].Path })
+	for _, e := range dirs {
+		p, _ := t.abs(e.Path)
+		err := os.Chmod(p, perm(e.Mode, 0755))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// abs convert a slash separated entry path to the absolute path inside the tree
+func (t *Tree) abs(path string) (string, error) {
+	p := filepath.Clean(filepath.FromSlash(path))
+	if len(path) == 0 || filepath.IsAbs(p) || p == "." || p == ".." || strings.HasPrefix(p, ".."+string(os.PathSeparator)) {
+		return "", fmt.Errorf("tree entry path %q must be relative and inside the tree", path)
+	}
+	return filepath.Join(t.Root, p), nil
+}
+
+func perm(mode, def os.FileMode) os.FileMode {
+	if mode.Perm() == 0 {
+		return def
+	}
+	return mode.Perm()
+}
+
+// Path return the absolute path of the slash separated elements inside the tree
+func (t *Tree) Path(elem ...string) string {
+	return filepath.Join(append([]string{t.Root}, filepath.FromSlash(strings.Join(elem, "/")))...)
+}
+
+// Cleanup remove the whole tree, read-only directories included
+func (t *Tree) Cleanup() error {
+	filepath.Walk(t.Root, func(p string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			os.Chmod(p, 0700)
+		}
+		return nil
+	})
+	return os.RemoveAll(t.Root)
+}
+
+// Snapshot read the tree back into a TreeSpec sorted by path.
+// every node is listed, intermediate directories included, and symlinks are not followed.
+func (t *Tree) Snapshot() (TreeSpec, error) {
+	var spec TreeSpec
+
+	err := filepath.Walk(t.Root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == t.Root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(t.Root, p)
+		if err != nil {
+			return err
+		}
+		e := TreeEntry{Path: filepath.ToSlash(rel)}
+
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			e.Mode = os.ModeDir | mode.Perm()
+		case mode&os.ModeSymlink != 0:
+			// symlink permissions are meaningless on most systems
+			e.Mode = os.ModeSymlink
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			e.Target = filepath.ToSlash(target)
+		case mode.IsRegular():
+			e.Mode = mode.Perm()
+			b, err := ioutil.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			e.Content = string(b)
+		default:
+			e.Mode = mode
+		}
+
+		spec = append(spec, e)
+		return nil
+	})
+
+	return spec, err
+}
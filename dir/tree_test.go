@@ -0,0 +1,58 @@
+package dir
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestTempTreeSnapshot(t *testing.T) {
+	in := TreeSpec{
+		{Path: "bin/run", Mode: 0755, Content: "#!/bin/sh\n"},
+		{Path: "empty", Mode: os.ModeDir | 0700},
+		{Path: "etc/conf", Mode: 0600, Content: "a=b\n"},
+		{Path: "etc/conf.link", Mode: os.ModeSymlink, Target: "conf"},
+	}
+	tree, err := TempTree(in)
+	if err != nil {
+		t.Fatalf("[dir]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	correct := TreeSpec{
+		{Path: "bin", Mode: os.ModeDir | 0755},
+		{Path: "bin/run", Mode: 0755, Content: "#!/bin/sh\n"},
+		{Path: "empty", Mode: os.ModeDir | 0700},
+		{Path: "etc", Mode: os.ModeDir | 0755},
+		{Path: "etc/conf", Mode: 0600, Content: "a=b\n"},
+		{Path: "etc/conf.link", Mode: os.ModeSymlink, Target: "conf"},
+	}
+	out, err := tree.Snapshot()
+	if err != nil || !reflect.DeepEqual(out, correct) {
+		t.Errorf("[dir]Snapshot test failed, expecting %v, got %v, err %v", correct, out, err)
+	}
+}
+
+func TestTempTreeReadOnlyCleanup(t *testing.T) {
+	tree, err := TempTree(TreeSpec{
+		{Path: "ro", Mode: os.ModeDir | 0555},
+		{Path: "ro/file", Content: "x"},
+	})
+	if err != nil {
+		t.Fatalf("[dir]TempTree failed: %v", err)
+	}
+	if err := tree.Cleanup(); err != nil {
+		t.Errorf("[dir]Cleanup test failed, expecting nil, got %v", err)
+	}
+	if _, err := os.Stat(tree.Root); !os.IsNotExist(err) {
+		t.Errorf("[dir]Cleanup test failed, %s still exists", tree.Root)
+	}
+}
+
+func TestTempTreeEscape(t *testing.T) {
+	tree, err := TempTree(TreeSpec{{Path: "../escape", Content: "x"}})
+	if err == nil {
+		tree.Cleanup()
+		t.Error("[dir]TempTree test failed, expecting error for escaping path, got nil")
+	}
+}
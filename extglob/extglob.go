@@ -137,6 +137,13 @@ func list(p string, globalstar, tailing bool) ([]string, error) {
 
 // Expand expand extglob pattern to actual files/directories
 func Expand(b []byte, options ...bool) ([]string, error) {
+	return ExpandFunc(b, list, valid, options...)
+}
+
+// ExpandFunc expand extglob pattern against the tree fn lists and fn1 validates
+// instead of the filesystem, eg: the members of an archive. paths are absolute
+// in that tree, and fn lists the directory "" for the root.
+func ExpandFunc(b []byte, fn ListFunc, fn1 ValidFunc, options ...bool) ([]string, error) {
 	extglob := true
 	globalstar := true
 
@@ -150,7 +157,7 @@ func Expand(b []byte, options ...bool) ([]string, error) {
 		return []string{}, errors.New("only two available options: extglob and globalstar")
 	}
 
-	return expand(b, extglob, globalstar, list, valid)
+	return expand(b, extglob, globalstar, fn, fn1)
 }
 
 // ValidFunc valid if a path exits
@@ -0,0 +1,170 @@
+package fileutils
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/marguerite/go-stdlib/dir"
+	"github.com/marguerite/go-stdlib/internal"
+)
+
+// WriteOptions options for WriteAtomic
+type WriteOptions struct {
+	// Mode the permission of a new file, 0644 if zero. an existing file keeps its own.
+	Mode os.FileMode
+	// Backup keep the previous file as a backup, like `install --backup`
+	Backup BackupMode
+	// Suffix the suffix of simple backups, "~" if empty
+	Suffix string
+}
+
+// WriteAtomic replace the content of path with r so that readers see either
+// the old or the new content, even after a crash. the content is written to a
+// temporary file in the same directory, synced, given the mode and owner of the
+// previous file and renamed into place, then the directory is synced.
+// if path is a symlink, the file it points to is replaced, or created if missing.
+func WriteAtomic(path string, r io.Reader, opts WriteOptions) (err error) {
+	chain, err := dir.LinkChain(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	path = chain[len(chain)-1]
+
+	mode := opts.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+	prev, err := os.Stat(path)
+	switch {
+	case err == nil:
+		mode = prev.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	tmp, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.abort()
+		}
+	}()
+
+	_, err = io.Copy(tmp, r)
+	if err != nil {
+		return err
+	}
+
+	if prev != nil {
+		if uid, gid, ok := internal.StatOwner(prev); ok {
+			err = tmp.Chown(uid, gid)
+			if err != nil && !os.IsPermission(err) {
+				return err
+			}
+		}
+	}
+	// after chown, which clears the setuid bits
+	err = tmp.Chmod(mode)
+	if err != nil {
+		return err
+	}
+
+	err = tmp.close()
+	if err != nil {
+		return err
+	}
+	return tmp.commit(opts.Backup, opts.Suffix)
+}
+
+// atomicFile a temporary file next to target, which replaces target on commit
+type atomicFile struct {
+	*os.File
+	target string
+}
+
+func createAtomic(target string) (*atomicFile, error) {
+	f, err := ioutil.TempFile(filepath.Dir(target), "."+filepath.Base(target)+".tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &atomicFile{f, target}, nil
+}
+
+// close sync the content to disk and close the file, it can still be
+// changed by name before commit
+func (f *atomicFile) close() error {
+	err := f.Sync()
+	err1 := f.File.Close()
+	if err != nil {
+		return err
+	}
+	return err1
+}
+
+// abort drop the temporary file
+func (f *atomicFile) abort() {
+	f.File.Close()
+	os.Remove(f.Name())
+}
+
+// commit back up an existing target if asked, rename the temporary file over
+// target and sync the directory. the temporary file is removed on failure.
+func (f *atomicFile) commit(backup BackupMode, suffix string) error {
+	if backup != NoBackup {
+		if _, err := os.Lstat(f.target); err == nil {
+			err = linkBackup(f.target, backup, suffix)
+			if err != nil {
+				os.Remove(f.Name())
+				return err
+			}
+		}
+	}
+
+	err := os.Rename(f.Name(), f.target)
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return syncDir(filepath.Dir(f.target))
+}
+
+// linkBackup make the backup of path as a hard link, so path never goes missing,
+// or as a copy where hard links are not possible
+func linkBackup(path string, mode BackupMode, suffix string) error {
+	name, err := backupName(path, mode, suffix)
+	if err != nil {
+		return err
+	}
+	err = removeNonDir(name)
+	if err != nil {
+		return err
+	}
+	if os.Link(path, name) == nil {
+		return nil
+	}
+	c := newCopier(CopyOptions{PreserveMode: true, PreserveOwner: true, PreserveTimestamps: true})
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return c.file(path, name, fi)
+}
+
+// syncDir flush the directory entry changes of dir to disk
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		// directories can not be synced there
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
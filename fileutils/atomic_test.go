@@ -0,0 +1,66 @@
+package fileutils
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/marguerite/go-stdlib/dir"
+)
+
+func TestWriteAtomic(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "app.conf", Mode: 0600, Content: "v1"},
+		{Path: "app.link", Mode: os.ModeSymlink, Target: "app.conf"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	for _, v := range []string{"v2", "v3"} {
+		err = WriteAtomic(tree.Path("app.link"), strings.NewReader(v), WriteOptions{Backup: NumberedBackup})
+		if err != nil {
+			t.Fatalf("[fileutils]WriteAtomic test failed, expecting nil, got %v", err)
+		}
+	}
+
+	correct := dir.TreeSpec{
+		{Path: "app.conf", Mode: 0600, Content: "v3"},
+		{Path: "app.conf.~1~", Mode: 0600, Content: "v1"},
+		{Path: "app.conf.~2~", Mode: 0600, Content: "v2"},
+		{Path: "app.link", Mode: os.ModeSymlink, Target: "app.conf"},
+	}
+	spec, err := tree.Snapshot()
+	if err != nil || !reflect.DeepEqual(spec, correct) {
+		t.Errorf("[fileutils]WriteAtomic test failed, expecting %v, got %v, err %v", correct, spec, err)
+	}
+
+	err = WriteAtomic(tree.Path("new.conf"), strings.NewReader("new"), WriteOptions{Mode: 0640})
+	if fi, _ := os.Stat(tree.Path("new.conf")); err != nil || fi.Mode().Perm() != 0640 {
+		t.Errorf("[fileutils]WriteAtomic test failed, expecting new.conf with mode 0640, got %v, err %v", fi, err)
+	}
+
+	err = WriteAtomic(tree.Path("missing/new.conf"), strings.NewReader("new"), WriteOptions{})
+	if err == nil {
+		t.Error("[fileutils]WriteAtomic test failed, expecting error for a missing directory, got nil")
+	}
+	if items, _ := ioutil.ReadDir(tree.Root); len(items) != 5 {
+		t.Errorf("[fileutils]WriteAtomic test failed, expecting no temporary file left, got %d items", len(items))
+	}
+
+	// the target of a dangling symlink is created, the symlink kept
+	err = os.Symlink("target.conf", tree.Path("dangling"))
+	if err != nil {
+		t.Fatalf("[fileutils]Symlink failed: %v", err)
+	}
+	err = WriteAtomic(tree.Path("dangling"), strings.NewReader("target"), WriteOptions{})
+	if b, _ := ioutil.ReadFile(tree.Path("target.conf")); err != nil || string(b) != "target" {
+		t.Errorf("[fileutils]WriteAtomic dangling symlink test failed, expecting target, got %q, err %v", b, err)
+	}
+	if fi, err := os.Lstat(tree.Path("dangling")); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("[fileutils]WriteAtomic dangling symlink test failed, expecting the symlink kept, got %v, err %v", fi, err)
+	}
+}
@@ -0,0 +1,75 @@
+package fileutils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// BackupMode how an existing destination is backed up, like GNU's --backup=CONTROL
+type BackupMode int
+
+const (
+	// NoBackup never make backups, like --backup=none
+	NoBackup BackupMode = iota
+	// SimpleBackup append the suffix, "~" by default, like --backup=simple
+	SimpleBackup
+	// NumberedBackup append .~N~ with the next free N, like --backup=numbered
+	NumberedBackup
+	// ExistingBackup numbered if numbered backups exist already, simple otherwise, like --backup=existing
+	ExistingBackup
+)
+
+// backupName the name the backup of path will take
+func backupName(path string, mode BackupMode, suffix string) (string, error) {
+	if len(suffix) == 0 {
+		suffix = "~"
+	}
+	if mode == SimpleBackup {
+		return path + suffix, nil
+	}
+
+	items, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+	prefix := filepath.Base(path) + ".~"
+	max := 0
+	for _, item := range items {
+		name := item.Name()
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, "~") || len(name) <= len(prefix)+1 {
+			continue
+		}
+		n, err := strconv.Atoi(name[len(prefix) : len(name)-1])
+		if err == nil && n > max {
+			max = n
+		}
+	}
+
+	if mode == ExistingBackup && max == 0 {
+		return path + suffix, nil
+	}
+	return path + ".~" + strconv.Itoa(max+1) + "~", nil
+}
+
+// backup rename path to its backup name, it returns the backup path,
+// or an empty string if path does not exist or mode is NoBackup
+func backup(path string, mode BackupMode, suffix string) (string, error) {
+	if mode == NoBackup {
+		return "", nil
+	}
+	if _, err := os.Lstat(path); err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	name, err := backupName(path, mode, suffix)
+	if err != nil {
+		return "", err
+	}
+	return name, os.Rename(path, name)
+}
@@ -0,0 +1,213 @@
+package fileutils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrUnknownAlgorithm = errors.New("unknown checksum algorithm")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// newHash the hash of algo, one of md5, sha1, sha256 and sha512
+func newHash(algo string) (hash.Hash, error) {
+	switch strings.ToLower(algo) {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, algo)
+}
+
+// Checksum the hex encoded checksum of the content of path, algo is one of
+// md5, sha1, sha256 and sha512. the file is streamed, not read into memory.
+func Checksum(path, algo string) (string, error) {
+	h, err := newHash(algo)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// WriteManifest write the checksums of regular files matching the extglob pattern
+// to manifestPath in the format of sha256sum(1) and friends, one "<checksum>  <path>"
+// line per file, sorted by path. files under the directory of the manifest are
+// listed relative to it, so `sha256sum -c` works from there, the others by their
+// absolute paths. the manifest is written atomically, and never lists itself.
+func WriteManifest(manifestPath, pattern, algo string) error {
+	paths, err := expandPattern(pattern)
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	base, err := filepath.Abs(filepath.Dir(manifestPath))
+	if err != nil {
+		return err
+	}
+	mi, _ := os.Stat(manifestPath)
+
+	var buf bytes.Buffer
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() || (mi != nil && os.SameFile(fi, mi)) {
+			continue
+		}
+
+		sum, err := Checksum(p, algo)
+		if err != nil {
+			return err
+		}
+
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		// Check resolves relative names against base, not the working directory
+		name := abs
+		if isWithin(base, abs) {
+			name, _ = filepath.Rel(base, abs)
+		}
+		name = filepath.ToSlash(name)
+
+		// like coreutils, a leading backslash marks an escaped name
+		if strings.ContainsAny(name, "\\\n") {
+			buf.WriteByte('\\')
+			name = strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(name)
+		}
+		buf.WriteString(sum + "  " + name + "\n")
+	}
+	return WriteAtomic(manifestPath, &buf, WriteOptions{Mode: 0644})
+}
+
+// CheckResult the verification of one file listed in a manifest
+type CheckResult struct {
+	Path string
+	OK   bool
+	// Err why the file could not be read, the checksum mismatched otherwise
+	Err error
+}
+
+// Check like `sha256sum -c`, verify the files listed in the manifest at
+// manifestPath. the algorithm is told by the length of the checksums, relative
+// paths are relative to the directory of the manifest. it returns the result of
+// every file, and ErrChecksumMismatch if any of them failed.
+func Check(manifestPath string) ([]CheckResult, error) {
+	var results []CheckResult
+
+	f, err := os.Open(manifestPath)
+	if err != nil {
+		return results, err
+	}
+	defer f.Close()
+
+	base := filepath.Dir(manifestPath)
+	failed := 0
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		sum, name, err := parseManifestLine(line)
+		if err != nil {
+			return results, fmt.Errorf("%s:%d: %v", manifestPath, n, err)
+		}
+
+		path := filepath.FromSlash(name)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(base, path)
+		}
+
+		result := CheckResult{Path: path}
+		actual, err := Checksum(path, algoOf(sum))
+		if err != nil {
+			result.Err = err
+		} else {
+			result.OK = strings.EqualFold(actual, sum)
+		}
+		if !result.OK {
+			failed++
+		}
+		results = append(results, result)
+	}
+	if err := scanner.Err(); err != nil {
+		return results, err
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("%w: %d of %d files failed", ErrChecksumMismatch, failed, len(results))
+	}
+	return results, nil
+}
+
+// parseManifestLine split a "<checksum>  <path>" or "<checksum> *<path>" line
+func parseManifestLine(line string) (sum, name string, err error) {
+	escaped := strings.HasPrefix(line, "\\")
+	if escaped {
+		line = line[1:]
+	}
+
+	i := strings.IndexByte(line, ' ')
+	if i < 0 || i+2 > len(line) || (line[i+1] != ' ' && line[i+1] != '*') {
+		return "", "", fmt.Errorf("improperly formatted checksum line %q", line)
+	}
+	sum, name = line[:i], line[i+2:]
+	if len(algoOf(sum)) == 0 {
+		return "", "", fmt.Errorf("improperly formatted checksum %q", sum)
+	}
+	if _, err := hex.DecodeString(sum); err != nil {
+		return "", "", fmt.Errorf("improperly formatted checksum %q", sum)
+	}
+
+	if escaped {
+		name = strings.NewReplacer("\\\\", "\\", "\\n", "\n").Replace(name)
+	}
+	return sum, name, nil
+}
+
+// algoOf the algorithm of a hex encoded checksum by its length
+func algoOf(sum string) string {
+	switch len(sum) {
+	case 2 * md5.Size:
+		return "md5"
+	case 2 * sha1.Size:
+		return "sha1"
+	case 2 * sha256.Size:
+		return "sha256"
+	case 2 * sha512.Size:
+		return "sha512"
+	}
+	return ""
+}
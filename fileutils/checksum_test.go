@@ -0,0 +1,112 @@
+package fileutils
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/marguerite/go-stdlib/dir"
+)
+
+func TestChecksum(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "a", Content: "abc"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	tests := map[string]string{
+		"md5":    "900150983cd24fb0d6963f7d28e17f72",
+		"sha1":   "a9993e364706816aba3e25717850c26c9cd0d89d",
+		"sha256": "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		"sha512": "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f",
+	}
+	for algo, correct := range tests {
+		sum, err := Checksum(tree.Path("a"), algo)
+		if err != nil || sum != correct {
+			t.Errorf("[fileutils]Checksum test failed for %s, expecting %s, got %s, err %v", algo, correct, sum, err)
+		}
+	}
+
+	_, err = Checksum(tree.Path("a"), "crc32")
+	if !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("[fileutils]Checksum test failed, expecting %v, got %v", ErrUnknownAlgorithm, err)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "dist/a.tar", Content: "abc"},
+		{Path: "dist/b.tar", Content: "def"},
+		{Path: "dist/doc", Mode: os.ModeDir | 0755},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	manifest := tree.Path("dist/SHA256SUMS")
+	err = WriteManifest(manifest, tree.Path("dist/*"), "sha256")
+	if err != nil {
+		t.Fatalf("[fileutils]WriteManifest test failed, expecting nil, got %v", err)
+	}
+	b, err := ioutil.ReadFile(manifest)
+	correct := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad  a.tar\n" +
+		"cb8379ac2098aa165029e3938a51da0bcecfc008fd6795f401178647f96c5b34  b.tar\n"
+	if err != nil || string(b) != correct {
+		t.Errorf("[fileutils]WriteManifest test failed, expecting %q, got %q, err %v", correct, b, err)
+	}
+
+	results, err := Check(manifest)
+	if err != nil || len(results) != 2 || !results[0].OK || !results[1].OK {
+		t.Errorf("[fileutils]Check test failed, expecting all OK, got %v, err %v", results, err)
+	}
+
+	err = ioutil.WriteFile(tree.Path("dist/b.tar"), []byte("changed"), 0644)
+	if err != nil {
+		t.Fatalf("[fileutils]WriteFile failed: %v", err)
+	}
+	results, err = Check(manifest)
+	if !errors.Is(err, ErrChecksumMismatch) || len(results) != 2 || !results[0].OK || results[1].OK {
+		t.Errorf("[fileutils]Check test failed, expecting b.tar to fail, got %v, err %v", results, err)
+	}
+}
+
+func TestManifestOutside(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "src/a.tar", Content: "abc"},
+		{Path: "sums", Mode: os.ModeDir | 0755},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("[fileutils]Getwd failed: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	// a relative pattern for files outside the directory of the manifest
+	os.Chdir(tree.Root)
+	err = WriteManifest("sums/SHA256SUMS", "src/*", "sha256")
+	if err != nil {
+		t.Fatalf("[fileutils]WriteManifest test failed, expecting nil, got %v", err)
+	}
+	b, err := ioutil.ReadFile(tree.Path("sums/SHA256SUMS"))
+	correct := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad  " + filepath.ToSlash(tree.Path("src/a.tar")) + "\n"
+	if err != nil || string(b) != correct {
+		t.Errorf("[fileutils]WriteManifest test failed, expecting %q, got %q, err %v", correct, b, err)
+	}
+
+	os.Chdir(tree.Path("sums"))
+	results, err := Check(tree.Path("sums/SHA256SUMS"))
+	if err != nil || len(results) != 1 || !results[0].OK {
+		t.Errorf("[fileutils]Check test failed, expecting all OK, got %v, err %v", results, err)
+	}
+}
@@ -0,0 +1,189 @@
+package fileutils
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/marguerite/go-stdlib/extglob"
+	"github.com/marguerite/go-stdlib/internal"
+)
+
+// ChmodOptions options for Chmod
+type ChmodOptions struct {
+	// Recursive change directories and their contents, like `chmod -R`.
+	// symlinks met on the way are not followed.
+	Recursive bool
+	// Reference use the mode of this file instead of the mode string, like `chmod --reference`
+	Reference string
+}
+
+// ModeChange a mode change Chmod made
+type ModeChange struct {
+	Path string
+	Old  os.FileMode
+	New  os.FileMode
+}
+
+// Chmod like Ruby's FileUtils.chmod_R, change the mode of files matching the
+// extglob pattern. mode is octal or symbolic, see ParseMode. a symlink matched by
+// the pattern is followed, like chmod does, but symlinks found while recursing have
+// no mode of their own and are skipped. it returns the changes actually made, like `chmod -c`.
+func Chmod(mode, pattern string, opts ChmodOptions) ([]ModeChange, error) {
+	var changes []ModeChange
+
+	var spec *ModeSpec
+	var err error
+	if len(opts.Reference) > 0 {
+		fi, err := os.Stat(opts.Reference)
+		if err != nil {
+			return changes, err
+		}
+		spec = &ModeSpec{octal: true, digits: 5, value: toUnixMode(fi.Mode())}
+	} else {
+		spec, err = ParseMode(mode)
+		if err != nil {
+			return changes, err
+		}
+	}
+
+	paths, err := expandPattern(pattern)
+	if err != nil {
+		return changes, err
+	}
+
+	umask := internal.Umask()
+	for _, p := range paths {
+		err := walkTop(p, opts.Recursive, true, func(path string, fi os.FileInfo) error {
+			if fi.Mode()&os.ModeSymlink != 0 {
+				return nil
+			}
+			m := spec.Apply(fi.Mode(), umask)
+			if m == fi.Mode() {
+				return nil
+			}
+			err := os.Chmod(path, m)
+			if err != nil {
+				return err
+			}
+			changes = append(changes, ModeChange{path, fi.Mode(), m})
+			return nil
+		})
+		if err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
+
+// ChownOptions options for Chown
+type ChownOptions struct {
+	// Recursive change directories and their contents, like `chown -R`.
+	// symlinks met on the way are not followed.
+	Recursive bool
+	// Reference use the owner and group of this file instead, like `chown --reference`
+	Reference string
+	// NoDereference change symlinks themselves instead of what they point to, like `chown -h`
+	NoDereference bool
+}
+
+// OwnerChange an ownership change Chown made, ids are -1 where unknown
+type OwnerChange struct {
+	Path   string
+	OldUID int
+	OldGID int
+	UID    int
+	GID    int
+}
+
+// Chown like Ruby's FileUtils.chown_R, change the owner and group of files matching
+// the extglob pattern. owner and group are names or numeric ids, empty to keep.
+// it returns the changes actually made, like `chown -c`.
+func Chown(owner, group, pattern string, opts ChownOptions) ([]OwnerChange, error) {
+	var changes []OwnerChange
+
+	var uid, gid int
+	var err error
+	if len(opts.Reference) > 0 {
+		fi, err := os.Stat(opts.Reference)
+		if err != nil {
+			return changes, err
+		}
+		uid, gid, _ = internal.StatOwner(fi)
+	} else {
+		uid, gid, err = internal.LookupOwner(owner, group)
+		if err != nil {
+			return changes, err
+		}
+	}
+
+	paths, err := expandPattern(pattern)
+	if err != nil {
+		return changes, err
+	}
+
+	for _, p := range paths {
+		err := walkTop(p, opts.Recursive, !opts.NoDereference, func(path string, fi os.FileInfo) error {
+			oldUID, oldGID, _ := internal.StatOwner(fi)
+			if (uid == -1 || uid == oldUID) && (gid == -1 || gid == oldGID) {
+				return nil
+			}
+
+			var err error
+			if fi.Mode()&os.ModeSymlink != 0 {
+				err = os.Lchown(path, uid, gid)
+			} else {
+				err = os.Chown(path, uid, gid)
+			}
+			if err != nil {
+				return err
+			}
+
+			change := OwnerChange{path, oldUID, oldGID, uid, gid}
+			if uid == -1 {
+				change.UID = oldUID
+			}
+			if gid == -1 {
+				change.GID = oldGID
+			}
+			changes = append(changes, change)
+			return nil
+		})
+		if err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
+
+// expandPattern expand the extglob pattern, matching nothing is an error
+func expandPattern(pattern string) ([]string, error) {
+	paths, err := extglob.Expand(internal.Str2bytes(pattern))
+	if err != nil {
+		return paths, err
+	}
+	if len(paths) == 0 {
+		return paths, &os.PathError{Op: "expand", Path: pattern, Err: os.ErrNotExist}
+	}
+	return paths, nil
+}
+
+// walkTop call fn for path, which is followed if follow is set, and with recursive
+// for everything under it, children are visited without following symlinks
+func walkTop(path string, recursive, follow bool, fn func(string, os.FileInfo) error) error {
+	fi, err := statLink(path, !follow)
+	if err != nil {
+		return err
+	}
+	if !recursive || !fi.IsDir() {
+		return fn(path, fi)
+	}
+	return filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == path {
+			info = fi
+		}
+		return fn(p, info)
+	})
+}
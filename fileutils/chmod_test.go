@@ -0,0 +1,73 @@
+package fileutils
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/marguerite/go-stdlib/dir"
+)
+
+func TestChmod(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "pkg/bin/run", Mode: 0700},
+		{Path: "pkg/doc/README", Mode: 0600},
+		{Path: "pkg/doc/link", Mode: os.ModeSymlink, Target: "README"},
+		{Path: "pkg/bin", Mode: os.ModeDir | 0755},
+		{Path: "pkg/doc", Mode: os.ModeDir | 0700},
+		{Path: "ref", Mode: 0640},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	correct := []ModeChange{
+		{tree.Path("pkg/bin/run"), 0700, 0755},
+		{tree.Path("pkg/doc"), os.ModeDir | 0700, os.ModeDir | 0755},
+		{tree.Path("pkg/doc/README"), 0600, 0644},
+	}
+	changes, err := Chmod("u+rwX,go+rX,go-w", tree.Path("pkg"), ChmodOptions{Recursive: true})
+	if err != nil || !reflect.DeepEqual(changes, correct) {
+		t.Errorf("[fileutils]Chmod test failed, expecting %v, got %v, err %v", correct, changes, err)
+	}
+
+	changes, err = Chmod("", tree.Path("pkg/**/R*"), ChmodOptions{Reference: tree.Path("ref")})
+	correct = []ModeChange{{tree.Path("pkg/doc/README"), 0644, 0640}}
+	if err != nil || !reflect.DeepEqual(changes, correct) {
+		t.Errorf("[fileutils]Chmod Reference test failed, expecting %v, got %v, err %v", correct, changes, err)
+	}
+
+	// a symlink operand is followed
+	changes, err = Chmod("0600", tree.Path("pkg/doc/link"), ChmodOptions{})
+	correct = []ModeChange{{tree.Path("pkg/doc/link"), 0640, 0600}}
+	if err != nil || !reflect.DeepEqual(changes, correct) {
+		t.Errorf("[fileutils]Chmod symlink test failed, expecting %v, got %v, err %v", correct, changes, err)
+	}
+	if fi, err := os.Stat(tree.Path("pkg/doc/README")); err != nil || fi.Mode() != 0600 {
+		t.Errorf("[fileutils]Chmod symlink test failed, expecting the target 0600, got %v, err %v", fi, err)
+	}
+}
+
+func TestChown(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{{Path: "pkg/file"}, {Path: "ref"}})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	// changing to the current owner is always allowed and changes nothing
+	changes, err := Chown("", "", tree.Path("pkg"), ChownOptions{Recursive: true, Reference: tree.Path("ref")})
+	if err != nil || len(changes) != 0 {
+		t.Errorf("[fileutils]Chown test failed, expecting no change, got %v, err %v", changes, err)
+	}
+
+	if os.Getuid() != 0 {
+		t.Skip("[fileutils]Chown to another owner needs root")
+	}
+	changes, err = Chown("1", "1", tree.Path("pkg"), ChownOptions{Recursive: true})
+	correct := []OwnerChange{{tree.Path("pkg"), 0, 0, 1, 1}, {tree.Path("pkg/file"), 0, 0, 1, 1}}
+	if err != nil || !reflect.DeepEqual(changes, correct) {
+		t.Errorf("[fileutils]Chown test failed, expecting %v, got %v, err %v", correct, changes, err)
+	}
+}
@@ -0,0 +1,93 @@
+package fileutils
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// Identical whether a and b are the same file, same device and inode,
+// like `test a -ef b`. symlinks are followed.
+func Identical(a, b string) (bool, error) {
+	fi1, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	fi2, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(fi1, fi2), nil
+}
+
+// Difference where two files first differ, counting bytes and lines from 1 like cmp(1)
+type Difference struct {
+	// Offset the first differing byte. if one file is a prefix of the other,
+	// the byte right after the end of the shorter one
+	Offset int64
+	// Line the line of Offset
+	Line int64
+	// EOF the file that ended first, empty if a byte differs
+	EOF string
+}
+
+// Cmp like Linux's cmp command, compare the contents of a and b byte by byte.
+// it returns nil if they are the same.
+func Cmp(a, b string) (*Difference, error) {
+	if same, err := Identical(a, b); err != nil || same {
+		return nil, err
+	}
+
+	f1, err := os.Open(a)
+	if err != nil {
+		return nil, err
+	}
+	defer f1.Close()
+	f2, err := os.Open(b)
+	if err != nil {
+		return nil, err
+	}
+	defer f2.Close()
+
+	buf1 := make([]byte, 64*1024)
+	buf2 := make([]byte, 64*1024)
+	off, line := int64(1), int64(1)
+	for {
+		n1, err1 := io.ReadFull(f1, buf1)
+		if err1 != nil && err1 != io.EOF && err1 != io.ErrUnexpectedEOF {
+			return nil, err1
+		}
+		n2, err2 := io.ReadFull(f2, buf2)
+		if err2 != nil && err2 != io.EOF && err2 != io.ErrUnexpectedEOF {
+			return nil, err2
+		}
+
+		n := n1
+		if n2 < n {
+			n = n2
+		}
+		for i := 0; i < n; i++ {
+			if buf1[i] != buf2[i] {
+				return &Difference{Offset: off + int64(i), Line: line + int64(bytes.Count(buf1[:i], []byte{'\n'}))}, nil
+			}
+		}
+		off += int64(n)
+		line += int64(bytes.Count(buf1[:n], []byte{'\n'}))
+
+		switch {
+		case n1 < n2:
+			return &Difference{Offset: off, Line: line, EOF: a}, nil
+		case n2 < n1:
+			return &Difference{Offset: off, Line: line, EOF: b}, nil
+		case err1 != nil:
+			// both ended
+			return nil, nil
+		}
+	}
+}
+
+// sameContent compare the contents of two files chunk by chunk
+func sameContent(a, b string) (bool, error) {
+	d, err := Cmp(a, b)
+	return d == nil && err == nil, err
+}
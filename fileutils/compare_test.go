@@ -0,0 +1,56 @@
+package fileutils
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/marguerite/go-stdlib/dir"
+)
+
+func TestIdentical(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "a", Content: "a"},
+		{Path: "b", Content: "a"},
+		{Path: "link", Mode: os.ModeSymlink, Target: "a"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	tests := map[string]bool{"link": true, "b": false}
+	for name, correct := range tests {
+		same, err := Identical(tree.Path("a"), tree.Path(name))
+		if err != nil || same != correct {
+			t.Errorf("[fileutils]Identical test failed for %s, expecting %v, got %v, err %v", name, correct, same, err)
+		}
+	}
+}
+
+func TestCmp(t *testing.T) {
+	long := strings.Repeat("line\n", 20000)
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "a", Content: long + "end"},
+		{Path: "same", Content: long + "end"},
+		{Path: "differ", Content: long + "eNd"},
+		{Path: "short", Content: long},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	tests := map[string]*Difference{
+		"same":   nil,
+		"differ": {Offset: int64(len(long)) + 2, Line: 20001},
+		"short":  {Offset: int64(len(long)) + 1, Line: 20001, EOF: tree.Path("short")},
+	}
+	for name, correct := range tests {
+		d, err := Cmp(tree.Path("a"), tree.Path(name))
+		if err != nil || !reflect.DeepEqual(d, correct) {
+			t.Errorf("[fileutils]Cmp test failed for %s, expecting %v, got %v, err %v", name, correct, d, err)
+		}
+	}
+}
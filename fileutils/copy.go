@@ -0,0 +1,575 @@
+package fileutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/marguerite/go-stdlib/dir"
+	"github.com/marguerite/go-stdlib/extglob"
+	"github.com/marguerite/go-stdlib/internal"
+	"github.com/marguerite/go-stdlib/xattr"
+)
+
+// defaultBufferSize the copy buffer size when CopyOptions.BufferSize is zero
+const defaultBufferSize = 128 * 1024
+
+// ReflinkMode whether copy-on-write clones are used, like cp's --reflink
+type ReflinkMode int
+
+const (
+	// ReflinkAuto clone when the filesystem supports it, copy otherwise
+	ReflinkAuto ReflinkMode = iota
+	// ReflinkAlways clone or fail
+	ReflinkAlways
+	// ReflinkNever always copy the data
+	ReflinkNever
+)
+
+// CopyOptions options for CopyWithOptions
+type CopyOptions struct {
+	// Archive like `cp -a`, implies all the options below
+	Archive bool
+	// NoDereference copy symlinks as symlinks instead of the files they point to
+	NoDereference bool
+	// PreserveMode keep permission bits, setuid, setgid and sticky included
+	PreserveMode bool
+	// PreserveOwner keep owner and group, silently skipped without the privilege
+	PreserveOwner bool
+	// PreserveTimestamps keep access and modification times
+	PreserveTimestamps bool
+	// PreserveXattrs keep extended attributes, POSIX ACLs and SELinux labels included
+	PreserveXattrs bool
+	// PreserveLinks keep hard links between copied files as hard links
+	PreserveLinks bool
+	// BufferSize the size of the buffer used to stream file contents, 128KiB if zero
+	BufferSize int
+	// Reflink whether to clone files on copy-on-write filesystems like btrfs and xfs
+	Reflink ReflinkMode
+	// Progress receive the progress of the copy, the sources are scanned ahead for the totals
+	Progress ProgressFunc
+	// ProgressInterval the minimum time between two progress reports, 100ms if zero
+	ProgressInterval time.Duration
+	// Root confine the copy to the directory Root, empty for none. with dir.RejectEscapes,
+	// sources and destinations leading out of Root, symlinks resolved, fail with
+	// dir.ErrEscapesBase. with dir.ClampEscapes, such sources are skipped and
+	// destinations are resolved within Root like dir.SecureJoin does, outside paths
+	// being taken as if Root was the root directory. Root and Confine go together,
+	// one without the other is an error, like for dir.Glob.
+	Root    string
+	Confine dir.Confinement
+}
+
+// copier copies one or more trees with the same options
+type copier struct {
+	opts CopyOptions
+	buf  []byte
+	// ctx stops the copy when done, between files and between chunks of a file
+	ctx  context.Context
+	prog *progress
+	// counted the bytes of the current file accounted to prog so far
+	counted int64
+	// copied hard links, source file to its first destination
+	links map[internal.FileID]string
+	// directories whose attributes are applied after their contents are copied
+	dirs []dirAttr
+	// directories being copied, to detect loops through dereferenced symlinks
+	ancestors map[internal.FileID]bool
+}
+
+type dirAttr struct {
+	source  string
+	target  string
+	fi      os.FileInfo
+	created bool
+}
+
+func newCopier(opts CopyOptions) *copier {
+	if opts.Archive {
+		opts.NoDereference = true
+		opts.PreserveMode = true
+		opts.PreserveOwner = true
+		opts.PreserveTimestamps = true
+		opts.PreserveXattrs = true
+		opts.PreserveLinks = true
+	}
+	size := opts.BufferSize
+	if size <= 0 {
+		size = defaultBufferSize
+	}
+	return &copier{
+		opts:      opts,
+		buf:       make([]byte, size),
+		ctx:       context.Background(),
+		links:     make(map[internal.FileID]string),
+		ancestors: make(map[internal.FileID]bool),
+	}
+}
+
+// CopyWithOptions like Linux's cp command with -R, copy files/directories matching
+// the extglob pattern src to dest. if dest is an existing directory, sources are
+// copied into it, otherwise dest becomes the copy. unlike Copy, symlinks can be kept,
+// empty directories are copied and the copy is streamed through a bounded buffer.
+func CopyWithOptions(src, dest string, opts CopyOptions) error {
+	return CopyContext(context.Background(), src, dest, opts)
+}
+
+// CopyContext CopyWithOptions that can be cancelled through ctx. a file
+// being copied when ctx is done is removed, the files already copied are kept.
+func CopyContext(ctx context.Context, src, dest string, opts CopyOptions) error {
+	sources, err := extglob.Expand(internal.Str2bytes(src))
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(opts.Root) > 0 && opts.Confine == dir.NoConfinement:
+		return fmt.Errorf("root %s needs a confinement", opts.Root)
+	case len(opts.Root) == 0 && opts.Confine != dir.NoConfinement:
+		return fmt.Errorf("confinement needs a root")
+	}
+	if len(opts.Root) > 0 {
+		sources, err = confineSources(sources, opts)
+		if err != nil {
+			return err
+		}
+	}
+	if len(sources) == 0 {
+		return &os.PathError{Op: "copy", Path: src, Err: os.ErrNotExist}
+	}
+	if len(sources) > 1 {
+		if di, err := os.Stat(dest); err != nil || !di.IsDir() {
+			return fmt.Errorf("target %s is not a directory", dest)
+		}
+	}
+
+	c := newCopier(opts)
+	c.ctx = ctx
+	c.prog = newProgress(opts.Progress, opts.ProgressInterval)
+	defer c.prog.flush()
+	if c.prog != nil {
+		for _, v := range sources {
+			files, bytes, err := scanTree(v, !opts.NoDereference)
+			if err != nil {
+				return err
+			}
+			c.prog.total(files, bytes)
+		}
+	}
+
+	for _, v := range sources {
+		err := c.copy(v, dest)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copy copy the single source to dest, following cp's rule for existing directories
+func (c *copier) copy(source, dest string) error {
+	fi, err := c.stat(source)
+	if err != nil {
+		return err
+	}
+
+	target := dest
+	if di, err := os.Stat(dest); err == nil && di.IsDir() {
+		target = filepath.Join(dest, filepath.Base(source))
+	}
+
+	if fi.IsDir() && isWithin(source, target) {
+		return fmt.Errorf("cannot copy a directory %s into itself %s", source, target)
+	}
+
+	err = c.walk(source, target, fi)
+	if err != nil {
+		return err
+	}
+	return c.finish()
+}
+
+// stat lstat when symlinks are kept, stat otherwise
+func (c *copier) stat(p string) (os.FileInfo, error) {
+	if c.opts.NoDereference {
+		return os.Lstat(p)
+	}
+	return os.Stat(p)
+}
+
+// confineSources check the sources are within opts.Root, or drop those which are not
+func confineSources(sources []string, opts CopyOptions) ([]string, error) {
+	var kept []string
+	for _, v := range sources {
+		if dir.IsWithin(opts.Root, v) {
+			kept = append(kept, v)
+			continue
+		}
+		if opts.Confine == dir.RejectEscapes {
+			return nil, &os.PathError{Op: "copy", Path: v, Err: dir.ErrEscapesBase}
+		}
+	}
+	return kept, nil
+}
+
+// confine check target is within opts.Root, or resolve it within
+func (c *copier) confine(target string) (string, error) {
+	root := c.opts.Root
+	if len(root) == 0 {
+		return target, nil
+	}
+	if c.opts.Confine == dir.RejectEscapes {
+		if !dir.IsWithin(root, target) {
+			return target, &os.PathError{Op: "copy", Path: target, Err: dir.ErrEscapesBase}
+		}
+		return target, nil
+	}
+
+	p, err := filepath.Abs(target)
+	if err != nil {
+		return target, err
+	}
+	if isWithin(root, p) {
+		root, err = filepath.Abs(root)
+		if err != nil {
+			return target, err
+		}
+		p, err = filepath.Rel(root, p)
+		if err != nil {
+			return target, err
+		}
+	}
+	return dir.SecureJoin(root, p)
+}
+
+// walk copy source whose FileInfo is fi to target recursively
+func (c *copier) walk(source, target string, fi os.FileInfo) error {
+	err := c.ctx.Err()
+	if err != nil {
+		return err
+	}
+	target, err = c.confine(target)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return c.dir(source, target, fi)
+	}
+
+	c.prog.start(source)
+	c.counted = 0
+	switch mode := fi.Mode(); {
+	case mode&os.ModeSymlink != 0:
+		err = c.symlink(source, target, fi)
+	case mode.IsRegular():
+		err = c.file(source, target, fi)
+	default:
+		err = c.special(source, target, fi)
+	}
+	if err != nil {
+		return err
+	}
+	c.done(fi)
+	return nil
+}
+
+// done account the file whose FileInfo is fi as done, with the bytes the
+// copy did not report, like those of holes and reflinks
+func (c *copier) done(fi os.FileInfo) {
+	var rest int64
+	if fi.Mode().IsRegular() {
+		rest = fi.Size() - c.counted
+	}
+	c.prog.done(1, rest)
+}
+
+// advance account n more bytes of the current file copied, and check for cancellation
+func (c *copier) advance(n int64) error {
+	c.counted += n
+	c.prog.bytes(n)
+	return c.ctx.Err()
+}
+
+func (c *copier) dir(source, target string, fi os.FileInfo) error {
+	id, _, ok := internal.StatID(fi)
+	if ok {
+		if c.ancestors[id] {
+			return fmt.Errorf("symlink loop detected at %s", source)
+		}
+		c.ancestors[id] = true
+		defer delete(c.ancestors, id)
+	}
+
+	di, err := os.Lstat(target)
+	switch {
+	case err == nil && !di.IsDir():
+		return fmt.Errorf("cannot overwrite non-directory %s with directory %s", target, source)
+	case os.IsNotExist(err):
+		// owner write permission is needed to populate the directory, it is fixed up later
+		err = os.Mkdir(target, fi.Mode().Perm()|0700)
+		if err != nil {
+			return err
+		}
+		c.dirs = append(c.dirs, dirAttr{source, target, fi, true})
+	case err != nil:
+		return err
+	default:
+		if c.opts.PreserveMode || c.opts.PreserveOwner || c.opts.PreserveTimestamps || c.opts.PreserveXattrs {
+			c.dirs = append(c.dirs, dirAttr{source, target, fi, false})
+		}
+	}
+
+	items, err := ioutil.ReadDir(source)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		s := filepath.Join(source, item.Name())
+		if !c.opts.NoDereference && item.Mode()&os.ModeSymlink != 0 {
+			item, err = os.Stat(s)
+			if err != nil {
+				return err
+			}
+		}
+		err = c.walk(s, filepath.Join(target, item.Name()), item)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *copier) symlink(source, target string, fi os.FileInfo) error {
+	link, err := os.Readlink(source)
+	if err != nil {
+		return err
+	}
+
+	err = removeNonDir(target)
+	if err != nil {
+		return err
+	}
+
+	err = os.Symlink(link, target)
+	if err != nil {
+		return err
+	}
+	return c.attrs(source, target, fi)
+}
+
+func (c *copier) file(source, target string, fi os.FileInfo) error {
+	if di, err := os.Stat(target); err == nil && di.IsDir() {
+		return fmt.Errorf("cannot overwrite directory %s with non-directory %s", target, source)
+	}
+
+	if c.opts.PreserveLinks {
+		if id, nlink, ok := internal.StatID(fi); ok && nlink > 1 {
+			if first, ok := c.links[id]; ok {
+				err := removeNonDir(target)
+				if err != nil {
+					return err
+				}
+				return os.Link(first, target)
+			}
+			c.links[id] = target
+		}
+	}
+
+	err := c.content(source, target, fi)
+	if err != nil {
+		return err
+	}
+	return c.attrs(source, target, fi)
+}
+
+// content copy the content of source to target, target is created with the
+// permission of source like cp does, or truncated if it exists.
+// the fastest way the system offers is used, see transfer.
+func (c *copier) content(source, target string, fi os.FileInfo) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	err = c.transfer(out, in, fi)
+	err1 := out.Close()
+	if err != nil {
+		// leave no partially written file, eg: on cancellation
+		os.Remove(target)
+		return err
+	}
+	return err1
+}
+
+// copyBuffered copy n bytes of in from offset off to the same offset of out
+// through the bounded buffer, it stops early at the end of in
+func (c *copier) copyBuffered(out, in *os.File, off, n int64) error {
+	_, err := out.Seek(off, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	// progressWriter hides io.ReaderFrom so the bounded buffer is really used
+	_, err = io.CopyBuffer(progressWriter{out, c}, io.NewSectionReader(in, off, n), c.buf)
+	return err
+}
+
+// progressWriter account what is written to the copier, failing on cancellation
+type progressWriter struct {
+	w io.Writer
+	c *copier
+}
+
+func (p progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	if err == nil {
+		err = p.c.advance(int64(n))
+	}
+	return n, err
+}
+
+func (c *copier) special(source, target string, fi os.FileInfo) error {
+	err := removeNonDir(target)
+	if err != nil {
+		return err
+	}
+	err = mknod(target, fi)
+	if err != nil {
+		return err
+	}
+	return c.attrs(source, target, fi)
+}
+
+// attrs apply the preserved attributes of source to target in the order that
+// keeps them: xattrs, then owner which clears setuid bits, then mode, then times.
+func (c *copier) attrs(source, target string, fi os.FileInfo) error {
+	link := fi.Mode()&os.ModeSymlink != 0
+
+	if c.opts.PreserveXattrs {
+		err := copyXattrs(source, target)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.opts.PreserveOwner {
+		if uid, gid, ok := internal.StatOwner(fi); ok {
+			err := os.Lchown(target, uid, gid)
+			// like cp, ownership is kept only when we are allowed to
+			if err != nil && !os.IsPermission(err) {
+				return err
+			}
+		}
+	}
+
+	if c.opts.PreserveMode && !link {
+		err := os.Chmod(target, fi.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky))
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.opts.PreserveTimestamps {
+		return internal.Lutimes(target, internal.Atime(fi), fi.ModTime())
+	}
+	return nil
+}
+
+// copyXattrs copy the extended attributes of source to target, POSIX ACLs and
+// SELinux labels included, symlinks are not followed
+func copyXattrs(source, target string) error {
+	names, err := xattr.LList(source)
+	if err != nil {
+		if errors.Is(err, xattr.ErrNotSupported) {
+			return nil
+		}
+		return err
+	}
+
+	for _, name := range names {
+		val, err := xattr.LGet(source, name)
+		if err != nil {
+			if errors.Is(err, xattr.ErrNoAttribute) {
+				// removed in between
+				continue
+			}
+			return err
+		}
+		err = xattr.LSet(target, name, val)
+		// like cp -a, attributes the target filesystem or our privilege can not hold are skipped
+		if err != nil && !errors.Is(err, xattr.ErrNotSupported) && !os.IsPermission(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// finish apply the attributes of the copied directories, deepest first so
+// setting the timestamps of a directory is not undone by copying into it
+func (c *copier) finish() error {
+	for i := len(c.dirs) - 1; i >= 0; i-- {
+		d := c.dirs[i]
+		if d.created && !c.opts.PreserveMode {
+			// drop the owner permission added by dir
+			if extra := 0700 &^ d.fi.Mode().Perm(); extra != 0 {
+				di, err := os.Stat(d.target)
+				if err != nil {
+					return err
+				}
+				err = os.Chmod(d.target, di.Mode().Perm()&^extra)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		err := c.attrs(d.source, d.target, d.fi)
+		if err != nil {
+			return err
+		}
+	}
+	c.dirs = c.dirs[:0]
+	return nil
+}
+
+// removeNonDir remove path if it exists and is not a directory
+func removeNonDir(path string) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("cannot overwrite directory %s with non-directory", path)
+	}
+	return os.Remove(path)
+}
+
+// isWithin whether path is root or inside root, lexically
+func isWithin(root, path string) bool {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return false
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator)))
+}
@@ -0,0 +1,149 @@
+package fileutils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// transfer copy the content of in to out, trying in order a reflink clone,
+// copy_file_range(2), sendfile(2) and a buffered copy. with ReflinkNever neither
+// a clone nor copy_file_range(2), which shares extents on btrfs, XFS and NFS, is
+// tried, like `cp --reflink=never`. holes of sparse files are kept.
+func (c *copier) transfer(out, in *os.File, fi os.FileInfo) error {
+	if c.opts.Reflink != ReflinkNever {
+		err := unix.IoctlFileClone(int(out.Fd()), int(in.Fd()))
+		if err == nil {
+			return nil
+		}
+		if c.opts.Reflink == ReflinkAlways {
+			return &os.PathError{Op: "reflink", Path: out.Name(), Err: err}
+		}
+	}
+
+	size := fi.Size()
+	hole, err := unix.Seek(int(in.Fd()), 0, unix.SEEK_HOLE)
+	if err != nil || hole >= size {
+		// not sparse, or the filesystem can not tell
+		return c.copyRange(out, in, 0, size)
+	}
+
+	var off int64
+	for off < size {
+		data, err := unix.Seek(int(in.Fd()), off, unix.SEEK_DATA)
+		if err == unix.ENXIO {
+			// only a hole is left
+			break
+		}
+		if err != nil {
+			return &os.PathError{Op: "seek", Path: in.Name(), Err: err}
+		}
+		hole, err := unix.Seek(int(in.Fd()), data, unix.SEEK_HOLE)
+		if err != nil {
+			return &os.PathError{Op: "seek", Path: in.Name(), Err: err}
+		}
+		err = c.copyRange(out, in, data, hole-data)
+		if err != nil {
+			return err
+		}
+		off = hole
+	}
+	// a trailing hole is made by extending the file
+	return out.Truncate(size)
+}
+
+// copyRange copy n bytes of in from offset off to the same offset of out inside
+// the kernel if possible, falling back to the buffered copy
+func (c *copier) copyRange(out, in *os.File, off, n int64) error {
+	if c.opts.Reflink == ReflinkNever {
+		return c.sendfile(out, in, off, n)
+	}
+	roff, woff := off, off
+	for n > 0 {
+		written, err := unix.CopyFileRange(int(in.Fd()), &roff, int(out.Fd()), &woff, c.chunk(n), 0)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			// eg: ENOSYS on old kernels, EXDEV across filesystems before 5.3
+			return c.sendfile(out, in, roff, n)
+		}
+		if written == 0 {
+			return nil
+		}
+		n -= int64(written)
+		err = c.advance(int64(written))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendfile copy with sendfile(2), falling back to the buffered copy
+func (c *copier) sendfile(out, in *os.File, off, n int64) error {
+	_, err := out.Seek(off, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	for n > 0 {
+		written, err := unix.Sendfile(int(out.Fd()), int(in.Fd()), &off, c.chunk(n))
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return c.copyBuffered(out, in, off, n)
+		}
+		if written == 0 {
+			return nil
+		}
+		n -= int64(written)
+		err = c.advance(int64(written))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chunk the size of a single copy_file_range or sendfile call, which can not exceed
+// an int. it is smaller when progress is reported or the copy can be cancelled.
+func (c *copier) chunk(n int64) int {
+	limit := int64(1 << 30)
+	if c.prog != nil || c.ctx.Done() != nil {
+		limit = 8 << 20
+	}
+	if n > limit {
+		return int(limit)
+	}
+	return int(n)
+}
+
+// mknod recreate the fifo or device fi describes at path
+func mknod(path string, fi os.FileInfo) error {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("%s has unknown filemode %v", path, fi.Mode())
+	}
+
+	mode := uint32(fi.Mode().Perm())
+	switch m := fi.Mode(); {
+	case m&os.ModeNamedPipe != 0:
+		mode |= unix.S_IFIFO
+	case m&os.ModeCharDevice != 0:
+		mode |= unix.S_IFCHR
+	case m&os.ModeDevice != 0:
+		mode |= unix.S_IFBLK
+	default:
+		return fmt.Errorf("%s has unsupported filemode %v", path, m)
+	}
+
+	err := unix.Mknod(path, mode, int(st.Rdev))
+	if err != nil {
+		return &os.PathError{Op: "mknod", Path: path, Err: err}
+	}
+	return nil
+}
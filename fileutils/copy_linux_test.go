@@ -0,0 +1,105 @@
+package fileutils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/marguerite/go-stdlib/dir"
+	"github.com/marguerite/go-stdlib/xattr"
+	"golang.org/x/sys/unix"
+)
+
+func TestCopyWithOptionsXattrs(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{{Path: "src", Content: "x"}})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	err = unix.Setxattr(tree.Path("src"), "user.test", []byte("value"), 0)
+	if err != nil {
+		t.Skipf("[fileutils]xattrs are not supported here: %v", err)
+	}
+
+	err = CopyWithOptions(tree.Path("src"), tree.Path("dst"), CopyOptions{PreserveXattrs: true})
+	if err != nil {
+		t.Fatalf("[fileutils]CopyWithOptions test failed, expecting nil, got %v", err)
+	}
+
+	buf := make([]byte, 16)
+	n, err := unix.Getxattr(tree.Path("dst"), "user.test", buf)
+	if err != nil || string(buf[:n]) != "value" {
+		t.Errorf("[fileutils]CopyWithOptions test failed, expecting xattr user.test=value, got %s, err %v", buf[:n], err)
+	}
+}
+
+func TestCopyWithOptionsACL(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{{Path: "src", Mode: 0640, Content: "x"}})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	acl, _, _ := xattr.ParseACL("user::rw-,user:54321:r--,group::r--,mask::r--,other::---")
+	err = xattr.SetACL(tree.Path("src"), acl)
+	if err != nil {
+		t.Skipf("[fileutils]ACLs are not supported here: %v", err)
+	}
+
+	err = CopyWithOptions(tree.Path("src"), tree.Path("dst"), CopyOptions{PreserveXattrs: true, PreserveMode: true})
+	if err != nil {
+		t.Fatalf("[fileutils]CopyWithOptions test failed, expecting nil, got %v", err)
+	}
+
+	got, err := xattr.GetACL(tree.Path("dst"))
+	if err != nil || got.String() != acl.String() {
+		t.Errorf("[fileutils]CopyWithOptions test failed, expecting ACL %q, got %q, err %v", acl, got, err)
+	}
+}
+
+func TestCopyWithOptionsSparse(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{{Path: "src", Content: "head"}})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	// head, a 4MiB hole, tail and a trailing 4MiB hole
+	f, _ := os.OpenFile(tree.Path("src"), os.O_WRONLY, 0644)
+	f.WriteAt([]byte("tail"), 4<<20)
+	f.Truncate(8 << 20)
+	f.Close()
+
+	for _, mode := range []ReflinkMode{ReflinkAuto, ReflinkNever} {
+		os.Remove(tree.Path("dst"))
+		err = CopyWithOptions(tree.Path("src"), tree.Path("dst"), CopyOptions{Reflink: mode})
+		if err != nil {
+			t.Fatalf("[fileutils]CopyWithOptions reflink mode %d test failed, expecting nil, got %v", mode, err)
+		}
+
+		src, _ := ioutil.ReadFile(tree.Path("src"))
+		dst, _ := ioutil.ReadFile(tree.Path("dst"))
+		if !bytes.Equal(src, dst) {
+			t.Errorf("[fileutils]CopyWithOptions reflink mode %d test failed, content differs", mode)
+		}
+
+		var st unix.Stat_t
+		unix.Stat(tree.Path("dst"), &st)
+		if st.Blocks*512 >= 8<<20 {
+			t.Errorf("[fileutils]CopyWithOptions reflink mode %d test failed, expecting holes kept, got %d blocks", mode, st.Blocks)
+		}
+	}
+
+	// either cloned or refused, never silently copied
+	os.Remove(tree.Path("dst"))
+	err = CopyWithOptions(tree.Path("src"), tree.Path("dst"), CopyOptions{Reflink: ReflinkAlways})
+	if err == nil {
+		src, _ := ioutil.ReadFile(tree.Path("src"))
+		dst, _ := ioutil.ReadFile(tree.Path("dst"))
+		if !bytes.Equal(src, dst) {
+			t.Error("[fileutils]CopyWithOptions ReflinkAlways test failed, content differs")
+		}
+	}
+}
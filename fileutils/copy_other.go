@@ -0,0 +1,22 @@
+// +build !linux
+
+package fileutils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// transfer copy the content of in to out with a buffered copy, reflinks are Linux only
+func (c *copier) transfer(out, in *os.File, fi os.FileInfo) error {
+	if c.opts.Reflink == ReflinkAlways {
+		return &os.PathError{Op: "reflink", Path: out.Name(), Err: errors.New("reflinks are not supported on this system")}
+	}
+	return c.copyBuffered(out, in, 0, fi.Size())
+}
+
+// mknod fifos and devices are only recreated on Linux
+func mknod(path string, fi os.FileInfo) error {
+	return fmt.Errorf("%s has unsupported filemode %v", path, fi.Mode())
+}
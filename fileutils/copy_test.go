@@ -0,0 +1,176 @@
+package fileutils
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/marguerite/go-stdlib/dir"
+	"github.com/marguerite/go-stdlib/internal"
+)
+
+func TestCopyWithOptionsArchive(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "src/bin/run", Mode: 0750, Content: "#!/bin/sh\n"},
+		{Path: "src/empty", Mode: os.ModeDir | 0700},
+		{Path: "src/etc/conf", Mode: 0600, Content: "a=b\n"},
+		{Path: "src/etc/conf.link", Mode: os.ModeSymlink, Target: "conf"},
+		{Path: "dst", Mode: os.ModeDir},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	err = os.Link(tree.Path("src/etc/conf"), tree.Path("src/etc/conf.hard"))
+	if err != nil {
+		t.Fatalf("[fileutils]Link failed: %v", err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	os.Chtimes(tree.Path("src/bin/run"), mtime, mtime)
+	os.Chtimes(tree.Path("src/empty"), mtime, mtime)
+
+	err = CopyWithOptions(tree.Path("src"), tree.Path("dst"), CopyOptions{Archive: true, BufferSize: 3})
+	if err != nil {
+		t.Fatalf("[fileutils]CopyWithOptions test failed, expecting nil, got %v", err)
+	}
+
+	src, _ := (&dir.Tree{Root: tree.Path("src")}).Snapshot()
+	dst, err := (&dir.Tree{Root: tree.Path("dst/src")}).Snapshot()
+	if err != nil || !reflect.DeepEqual(src, dst) {
+		t.Errorf("[fileutils]CopyWithOptions test failed, expecting %v, got %v, err %v", src, dst, err)
+	}
+
+	for _, p := range []string{"dst/src/bin/run", "dst/src/empty"} {
+		if fi, err := os.Stat(tree.Path(p)); err != nil || !fi.ModTime().Equal(mtime) {
+			t.Errorf("[fileutils]CopyWithOptions test failed, expecting %s mtime %v, got %v, err %v", p, mtime, fi.ModTime(), err)
+		}
+	}
+
+	fi, _ := os.Stat(tree.Path("dst/src/etc/conf"))
+	fi1, _ := os.Stat(tree.Path("dst/src/etc/conf.hard"))
+	if !os.SameFile(fi, fi1) {
+		t.Error("[fileutils]CopyWithOptions test failed, expecting hard links to be preserved")
+	}
+}
+
+func TestCopyWithOptionsDereference(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "src/conf", Mode: 0600, Content: "a=b\n"},
+		{Path: "src/conf.link", Mode: os.ModeSymlink, Target: "conf"},
+		{Path: "src/sub/loop", Mode: os.ModeSymlink, Target: ".."},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	// a symlink loop is detected instead of copied forever
+	err = CopyWithOptions(tree.Path("src"), tree.Path("dst"), CopyOptions{})
+	if err == nil {
+		t.Error("[fileutils]CopyWithOptions loop test failed, expecting error, got nil")
+	}
+	os.Remove(tree.Path("src/sub/loop"))
+	os.RemoveAll(tree.Path("dst"))
+
+	err = CopyWithOptions(tree.Path("src"), tree.Path("dst"), CopyOptions{})
+	if err != nil {
+		t.Fatalf("[fileutils]CopyWithOptions test failed, expecting nil, got %v", err)
+	}
+	fi, err := os.Lstat(tree.Path("dst/conf.link"))
+	if err != nil || !fi.Mode().IsRegular() {
+		t.Errorf("[fileutils]CopyWithOptions test failed, expecting dereferenced regular file, got %v, err %v", fi, err)
+	}
+	if fi, err := os.Stat(tree.Path("dst/sub")); err != nil || !fi.IsDir() {
+		t.Errorf("[fileutils]CopyWithOptions test failed, expecting empty directory dst/sub, got err %v", err)
+	}
+
+	err = CopyWithOptions(tree.Path("src"), tree.Path("src/sub"), CopyOptions{})
+	if err == nil {
+		t.Error("[fileutils]CopyWithOptions test failed, expecting error copying a directory into itself, got nil")
+	}
+}
+
+func TestCopyWithOptionsOwner(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{{Path: "src", Content: "x"}})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	// the copy would be owned by the caller anyway, give src another owner first
+	uid, gid := os.Getuid(), -1
+	if uid == 0 {
+		uid, gid = 1, 1
+	} else {
+		groups, _ := os.Getgroups()
+		for _, v := range groups {
+			if v != os.Getegid() {
+				gid = v
+				break
+			}
+		}
+		if gid == -1 {
+			t.Skip("[fileutils]CopyWithOptions owner test needs root or a supplementary group")
+		}
+	}
+	err = os.Chown(tree.Path("src"), uid, gid)
+	if err != nil {
+		t.Fatalf("[fileutils]Chown failed: %v", err)
+	}
+
+	err = CopyWithOptions(tree.Path("src"), tree.Path("dst"), CopyOptions{PreserveOwner: true})
+	if err != nil {
+		t.Fatalf("[fileutils]CopyWithOptions test failed, expecting nil, got %v", err)
+	}
+	fi1, _ := os.Stat(tree.Path("dst"))
+	uid1, gid1, _ := internal.StatOwner(fi1)
+	if uid != uid1 || gid != gid1 {
+		t.Errorf("[fileutils]CopyWithOptions test failed, expecting owner %d:%d, got %d:%d", uid, gid, uid1, gid1)
+	}
+}
+
+func TestCopyWithOptionsRoot(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "root/src/conf", Content: "a=b\n"},
+		{Path: "root/dst/etc", Mode: os.ModeSymlink, Target: "../../outside"},
+		{Path: "root/outside", Mode: os.ModeDir},
+		{Path: "outside", Mode: os.ModeDir},
+		{Path: "secret", Content: "secret"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	root := tree.Path("root")
+	for _, opts := range []CopyOptions{{Root: root}, {Confine: dir.RejectEscapes}} {
+		err = CopyWithOptions(tree.Path("root/src/conf"), tree.Path("root/dst/etc/conf"), opts)
+		if err == nil {
+			t.Errorf("[fileutils]CopyWithOptions test failed, expecting an error for %+v, got nil", opts)
+		}
+	}
+
+	err = CopyWithOptions(tree.Path("root/src/conf"), tree.Path("root/dst/etc/conf"), CopyOptions{Root: root, Confine: dir.RejectEscapes})
+	if !errors.Is(err, dir.ErrEscapesBase) {
+		t.Errorf("[fileutils]CopyWithOptions test failed, expecting %v, got %v", dir.ErrEscapesBase, err)
+	}
+	err = CopyWithOptions(tree.Path("secret"), tree.Path("root/dst"), CopyOptions{Root: root, Confine: dir.RejectEscapes})
+	if !errors.Is(err, dir.ErrEscapesBase) {
+		t.Errorf("[fileutils]CopyWithOptions test failed, expecting %v, got %v", dir.ErrEscapesBase, err)
+	}
+
+	// the symlink is resolved within root
+	err = CopyWithOptions(tree.Path("root/src/conf"), tree.Path("root/dst/etc/conf"), CopyOptions{Root: root, Confine: dir.ClampEscapes})
+	if err != nil {
+		t.Fatalf("[fileutils]CopyWithOptions test failed, expecting nil, got %v", err)
+	}
+	if _, err := os.Stat(tree.Path("root/outside/conf")); err != nil {
+		t.Errorf("[fileutils]CopyWithOptions test failed, expecting the copy clamped into root, got %v", err)
+	}
+	if _, err := os.Stat(tree.Path("outside/conf")); !os.IsNotExist(err) {
+		t.Errorf("[fileutils]CopyWithOptions test failed, expecting nothing copied out of root, got %v", err)
+	}
+}
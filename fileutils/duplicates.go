@@ -0,0 +1,294 @@
+package fileutils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/marguerite/go-stdlib/internal"
+)
+
+// partialHashSize the bytes hashed from the head of files before hashing them whole
+const partialHashSize = 4096
+
+// DuplicateOptions options for FindDuplicates
+type DuplicateOptions struct {
+	// Includes consider only the files matching one of the extglob patterns, or under
+	// a directory matching, all if empty. like Excludes, a pattern without a slash
+	// matches names at any depth, one with a slash paths relative to each root.
+	Includes []string
+	// Excludes skip the paths matching one of the extglob patterns like `rsync --exclude`,
+	// directories with everything under them
+	Excludes []string
+	// MinSize skip files smaller than that, empty files are always skipped
+	MinSize int64
+	// Parallelism the number of files hashed at once, the number of CPUs if zero
+	Parallelism int
+}
+
+// dupFile a candidate of FindDuplicates
+type dupFile struct {
+	path string
+	size int64
+	// key what the files of a group have in common so far
+	key string
+}
+
+// FindDuplicates find the regular files with the same content under the roots,
+// which may be extglob patterns. files are grouped by size, then by the hash of
+// their first 4KiB, then by the hash of their whole content. symlinks are not
+// followed, and hard links to a file already found are skipped. each group is
+// sorted, and the groups are sorted by their first path.
+func FindDuplicates(roots []string, opts DuplicateOptions) ([][]string, error) {
+	var files []*dupFile
+	seen := make(map[internal.FileID]bool)
+	for _, pattern := range roots {
+		paths, err := expandPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, root := range paths {
+			found, err := findFiles(root, opts, seen)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, found...)
+		}
+	}
+
+	for _, f := range files {
+		f.key = fmt.Sprint(f.size)
+	}
+	files = regroup(files)
+
+	n := opts.Parallelism
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	err := hashFiles(files, n, partialHashSize)
+	if err != nil {
+		return nil, err
+	}
+	files = regroup(files)
+
+	// the partial hash of small files is already the whole
+	var big []*dupFile
+	for _, f := range files {
+		if f.size > partialHashSize {
+			big = append(big, f)
+		}
+	}
+	err = hashFiles(big, n, -1)
+	if err != nil {
+		return nil, err
+	}
+	files = regroup(files)
+
+	groups := make(map[string][]string)
+	for _, f := range files {
+		groups[f.key] = append(groups[f.key], f.path)
+	}
+	var dups [][]string
+	for _, g := range groups {
+		sort.Strings(g)
+		dups = append(dups, g)
+	}
+	sort.Slice(dups, func(i, j int) bool { return dups[i][0] < dups[j][0] })
+	return dups, nil
+}
+
+// findFiles the regular files under root not seen yet
+func findFiles(root string, opts DuplicateOptions, seen map[internal.FileID]bool) ([]*dupFile, error) {
+	var files []*dupFile
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		path = filepath.Clean(path)
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			if fi.IsDir() {
+				return nil
+			}
+			// root is a file itself
+			rel = filepath.Base(path)
+		}
+		excluded, err := matchPath(opts.Excludes, rel, fi.IsDir())
+		if err != nil {
+			return err
+		}
+		if excluded {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !fi.Mode().IsRegular() || fi.Size() == 0 || fi.Size() < opts.MinSize {
+			return nil
+		}
+		if len(opts.Includes) > 0 {
+			included, err := includedUnder(opts.Includes, rel)
+			if err != nil || !included {
+				return err
+			}
+		}
+		if id, _, ok := internal.StatID(fi); ok {
+			if seen[id] {
+				return nil
+			}
+			seen[id] = true
+		}
+		files = append(files, &dupFile{path: path, size: fi.Size()})
+		return nil
+	})
+	return files, err
+}
+
+// includedUnder whether the file rel or one of its parents matches the patterns
+func includedUnder(patterns []string, rel string) (bool, error) {
+	for p, isDir := rel, false; p != "." && p != string(filepath.Separator); p, isDir = filepath.Dir(p), true {
+		matched, err := matchPath(patterns, p, isDir)
+		if err != nil || matched {
+			return matched, err
+		}
+	}
+	return false, nil
+}
+
+// regroup drop the files whose key no other file has
+func regroup(files []*dupFile) []*dupFile {
+	count := make(map[string]int)
+	for _, f := range files {
+		count[f.key]++
+	}
+	var kept []*dupFile
+	for _, f := range files {
+		if count[f.key] > 1 {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// hashFiles add the hash of the first limit bytes of each file to its key,
+// the whole file if limit is negative, with n workers
+func hashFiles(files []*dupFile, n int, limit int64) error {
+	var mu sync.Mutex
+	var firstErr error
+	queue := make(chan *dupFile)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range queue {
+				mu.Lock()
+				failed := firstErr != nil
+				mu.Unlock()
+				if failed {
+					continue
+				}
+				sum, err := hashFile(f.path, limit)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				f.key += " " + sum
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, f := range files {
+		queue <- f
+	}
+	close(queue)
+	wg.Wait()
+	return firstErr
+}
+
+func hashFile(path string, limit int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if limit >= 0 {
+		r = io.LimitReader(f, limit)
+	}
+	h := sha256.New()
+	_, err = io.Copy(h, r)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// DedupeMode how Dedupe replaces duplicates
+type DedupeMode int
+
+const (
+	// DedupeHardlink replace duplicates with hard links, which share mode, owner and times
+	DedupeHardlink DedupeMode = iota
+	// DedupeReflink replace duplicates with copy-on-write clones, which keep their own
+	// mode, owner and times. it needs a filesystem like btrfs or xfs.
+	DedupeReflink
+)
+
+// Dedupe replace every file of each group but the first with a link to the first,
+// as FindDuplicates returns them. files changed since are left alone. duplicates
+// are replaced atomically. it returns the bytes freed, which do not count
+// duplicates with other hard links.
+func Dedupe(groups [][]string, mode DedupeMode) (int64, error) {
+	c := newCopier(CopyOptions{Reflink: ReflinkAlways, PreserveMode: true, PreserveOwner: true, PreserveTimestamps: true})
+	var freed int64
+	for _, g := range groups {
+		if len(g) < 2 {
+			continue
+		}
+		first, err := os.Stat(g[0])
+		if err != nil {
+			return freed, err
+		}
+		for _, p := range g[1:] {
+			fi, err := os.Lstat(p)
+			if err != nil {
+				return freed, err
+			}
+			if !fi.Mode().IsRegular() || os.SameFile(first, fi) {
+				continue
+			}
+			same, err := sameContent(g[0], p)
+			if err != nil {
+				return freed, err
+			}
+			if !same {
+				continue
+			}
+
+			if mode == DedupeReflink {
+				err = c.replace(g[0], p, fi)
+			} else {
+				err = replaceWithLink(g[0], p, false)
+			}
+			if err != nil {
+				return freed, err
+			}
+			// the data of a file with other hard links is still in use
+			if _, nlink, ok := internal.StatID(fi); !ok || nlink <= 1 {
+				freed += fi.Size()
+			}
+		}
+	}
+	return freed, nil
+}
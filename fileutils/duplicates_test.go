@@ -0,0 +1,98 @@
+package fileutils
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/marguerite/go-stdlib/dir"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	head := strings.Repeat("x", partialHashSize)
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "a/one", Content: "same"},
+		{Path: "a/two", Content: "same"},
+		{Path: "a/other", Content: "diff"},
+		{Path: "a/empty"},
+		{Path: "b/three", Content: "same"},
+		{Path: "b/big1", Content: head + "1"},
+		{Path: "b/big2", Content: head + "2"},
+		{Path: "b/big3", Content: head + "1"},
+		{Path: "b/skip/four", Content: "same"},
+		{Path: "a/sub/skip/five", Content: "same"},
+		{Path: "b/link", Mode: os.ModeSymlink, Target: "three"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+	err = os.Link(tree.Path("a/one"), tree.Path("a/hardlink"))
+	if err != nil {
+		t.Fatalf("[fileutils]Link failed: %v", err)
+	}
+
+	groups, err := FindDuplicates([]string{tree.Path("a"), tree.Path("b")}, DuplicateOptions{Excludes: []string{"skip"}, Parallelism: 2})
+	correct := [][]string{
+		{tree.Path("a/hardlink"), tree.Path("a/two"), tree.Path("b/three")},
+		{tree.Path("b/big1"), tree.Path("b/big3")},
+	}
+	if err != nil || !reflect.DeepEqual(groups, correct) {
+		t.Errorf("[fileutils]FindDuplicates test failed, expecting %v, got %v, err %v", correct, groups, err)
+	}
+
+	groups, err = FindDuplicates([]string{tree.Path("*")}, DuplicateOptions{Includes: []string{"@(one|three)"}})
+	correct = [][]string{{tree.Path("a/one"), tree.Path("b/three")}}
+	if err != nil || !reflect.DeepEqual(groups, correct) {
+		t.Errorf("[fileutils]FindDuplicates test failed, expecting %v, got %v, err %v", correct, groups, err)
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "one", Content: "same"},
+		{Path: "two", Content: "same"},
+		{Path: "three", Content: "same"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	groups, err := FindDuplicates([]string{tree.Root}, DuplicateOptions{})
+	if err != nil {
+		t.Fatalf("[fileutils]FindDuplicates test failed, expecting nil, got %v", err)
+	}
+	// changed after it was found
+	err = ioutil.WriteFile(tree.Path("two"), []byte("changed"), 0644)
+	if err != nil {
+		t.Fatalf("[fileutils]WriteFile failed: %v", err)
+	}
+
+	freed, err := Dedupe(groups, DedupeHardlink)
+	if err != nil || freed != 4 {
+		t.Errorf("[fileutils]Dedupe test failed, expecting 4 bytes freed, got %d, err %v", freed, err)
+	}
+	one, _ := os.Stat(tree.Path("one"))
+	two, _ := os.Stat(tree.Path("two"))
+	three, _ := os.Stat(tree.Path("three"))
+	if !os.SameFile(one, three) || os.SameFile(one, two) {
+		t.Errorf("[fileutils]Dedupe test failed, expecting only three linked to one")
+	}
+
+	// a duplicate with another hard link frees nothing
+	err = ioutil.WriteFile(tree.Path("four"), []byte("same"), 0644)
+	if err == nil {
+		err = os.Link(tree.Path("four"), tree.Path("four.link"))
+	}
+	if err != nil {
+		t.Fatalf("[fileutils]creating a hard link failed: %v", err)
+	}
+	freed, err = Dedupe([][]string{{tree.Path("one"), tree.Path("four")}}, DedupeHardlink)
+	four, _ := os.Stat(tree.Path("four"))
+	if err != nil || freed != 0 || !os.SameFile(one, four) {
+		t.Errorf("[fileutils]Dedupe hard link test failed, expecting 0 bytes freed, got %d, err %v", freed, err)
+	}
+}
@@ -0,0 +1,315 @@
+package fileutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// sniffSize the bytes read from the head of a file to tell its type
+const sniffSize = 8192
+
+// FileType the type of a file's content, like file(1) tells it
+type FileType struct {
+	// MIME the MIME type, eg: "application/x-executable" or "text/x-shellscript"
+	MIME string
+	// Charset the encoding of text, eg: "us-ascii", "utf-8", "utf-16le" or "iso-8859-1",
+	// "binary" for anything else
+	Charset string
+	// Description a human readable description, eg: "ELF 64-bit LSB executable"
+	Description string
+}
+
+// IsText whether the content is text, scripts included
+func (t FileType) IsText() bool {
+	return t.Charset != "binary"
+}
+
+// IsELF whether the content is an ELF object, which strip(1) understands
+func (t FileType) IsELF() bool {
+	return strings.HasPrefix(t.Description, "ELF ")
+}
+
+func (t FileType) String() string {
+	return t.MIME + "; charset=" + t.Charset
+}
+
+// magic a signature at the head of a file
+type magic struct {
+	offset      int
+	sig         string
+	mime        string
+	description string
+}
+
+// magics the signatures known, those of ELF, PE and scripts take some parsing and are not here
+var magics = []magic{
+	{0, "\x1f\x8b", "application/gzip", "gzip compressed data"},
+	{0, "\xfd7zXZ\x00", "application/x-xz", "XZ compressed data"},
+	{0, "\x28\xb5\x2f\xfd", "application/zstd", "Zstandard compressed data"},
+	{0, "BZh", "application/x-bzip2", "bzip2 compressed data"},
+	{0, "7z\xbc\xaf\x27\x1c", "application/x-7z-compressed", "7-zip archive data"},
+	{0, "PK\x03\x04", "application/zip", "Zip archive data"},
+	{0, "PK\x05\x06", "application/zip", "Zip archive data (empty)"},
+	{257, "ustar", "application/x-tar", "POSIX tar archive"},
+	{0, "\xed\xab\xee\xdb", "application/x-rpm", "RPM package"},
+	{0, "!<arch>\ndebian", "application/vnd.debian.binary-package", "Debian binary package"},
+	{0, "!<arch>\n", "application/x-archive", "current ar archive"},
+	{0, "\x89PNG\r\n\x1a\n", "image/png", "PNG image data"},
+	{0, "\xff\xd8\xff", "image/jpeg", "JPEG image data"},
+	{0, "GIF87a", "image/gif", "GIF image data, version 87a"},
+	{0, "GIF89a", "image/gif", "GIF image data, version 89a"},
+	{0, "%PDF-", "application/pdf", "PDF document"},
+	{0, "\x00asm", "application/wasm", "WebAssembly (wasm) binary module"},
+	{0, "\xfe\xed\xfa\xce", "application/x-mach-binary", "Mach-O executable"},
+	{0, "\xfe\xed\xfa\xcf", "application/x-mach-binary", "Mach-O 64-bit executable"},
+	{0, "\xce\xfa\xed\xfe", "application/x-mach-binary", "Mach-O executable"},
+	{0, "\xcf\xfa\xed\xfe", "application/x-mach-binary", "Mach-O 64-bit executable"},
+}
+
+// textTypes the MIME types of text told by extension
+var textTypes = map[string]string{
+	".c":    "text/x-c",
+	".h":    "text/x-c",
+	".cpp":  "text/x-c++",
+	".css":  "text/css",
+	".csv":  "text/csv",
+	".go":   "text/x-go",
+	".htm":  "text/html",
+	".html": "text/html",
+	".js":   "text/javascript",
+	".json": "application/json",
+	".md":   "text/markdown",
+	".py":   "text/x-script.python",
+	".rb":   "text/x-ruby",
+	".sh":   "text/x-shellscript",
+	".svg":  "image/svg+xml",
+	".xml":  "text/xml",
+	".yaml": "application/yaml",
+	".yml":  "application/yaml",
+}
+
+// interpreters the MIME types and descriptions of scripts by interpreter
+var interpreters = map[string][2]string{
+	"sh":     {"text/x-shellscript", "POSIX shell script"},
+	"bash":   {"text/x-shellscript", "Bourne-Again shell script"},
+	"zsh":    {"text/x-shellscript", "Paul Falstad's zsh script"},
+	"python": {"text/x-script.python", "Python script"},
+	"perl":   {"text/x-perl", "Perl script"},
+	"ruby":   {"text/x-ruby", "Ruby script"},
+	"node":   {"application/javascript", "Node.js script"},
+}
+
+// DetectType tell the type of the file at path by its content like file(1),
+// refined by its extension for text. symlinks are followed.
+func DetectType(path string) (FileType, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return FileType{}, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return FileType{}, err
+	}
+	if fi.IsDir() {
+		return FileType{"inode/directory", "binary", "directory"}, nil
+	}
+
+	t, err := DetectTypeReader(f)
+	if err != nil {
+		return t, err
+	}
+	if t.MIME == "text/plain" {
+		if mime, ok := textTypes[strings.ToLower(filepath.Ext(path))]; ok {
+			t.MIME = mime
+		}
+	}
+	return t, nil
+}
+
+// DetectTypeReader tell the type of the content r holds by its first 8KiB
+func DetectTypeReader(r io.Reader) (FileType, error) {
+	b := make([]byte, sniffSize)
+	n, err := io.ReadFull(r, b)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return FileType{}, err
+	}
+	return detect(b[:n], n == sniffSize), nil
+}
+
+// detect tell the type of head, the start of the content, cut short if truncated
+func detect(head []byte, truncated bool) FileType {
+	if len(head) == 0 {
+		return FileType{"inode/x-empty", "binary", "empty"}
+	}
+	if bytes.HasPrefix(head, []byte("\x7fELF")) {
+		return detectELF(head)
+	}
+	if bytes.HasPrefix(head, []byte("MZ")) {
+		return detectPE(head)
+	}
+	for _, m := range magics {
+		if len(head) >= m.offset+len(m.sig) && string(head[m.offset:m.offset+len(m.sig)]) == m.sig {
+			t := FileType{m.mime, "binary", m.description}
+			if m.mime == "application/pdf" {
+				// %PDF-1.7
+				if v := bytes.Fields(head[5:]); len(v) > 0 && len(v[0]) <= 4 {
+					t.Description += ", version " + string(v[0])
+				}
+			}
+			return t
+		}
+	}
+
+	charset := detectCharset(head, truncated)
+	if charset == "binary" {
+		return FileType{"application/octet-stream", charset, "data"}
+	}
+	if bytes.HasPrefix(head, []byte("#!")) {
+		return detectScript(head, charset)
+	}
+	return FileType{"text/plain", charset, charsetDescription(charset) + " text"}
+}
+
+// detectELF describe an ELF object by its class, byte order and type
+func detectELF(head []byte) FileType {
+	t := FileType{"application/x-executable", "binary", "ELF"}
+	if len(head) < 18 {
+		return t
+	}
+
+	switch head[4] {
+	case 1:
+		t.Description += " 32-bit"
+	case 2:
+		t.Description += " 64-bit"
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	switch head[5] {
+	case 1:
+		t.Description += " LSB"
+	case 2:
+		t.Description += " MSB"
+		order = binary.BigEndian
+	}
+
+	switch order.Uint16(head[16:]) {
+	case 1:
+		t.MIME, t.Description = "application/x-object", t.Description+" relocatable"
+	case 2:
+		t.Description += " executable"
+	case 3:
+		t.MIME, t.Description = "application/x-sharedlib", t.Description+" shared object"
+	case 4:
+		t.MIME, t.Description = "application/x-coredump", t.Description+" core file"
+	}
+	return t
+}
+
+// detectPE tell a PE executable from an MS-DOS one by the header e_lfanew points to
+func detectPE(head []byte) FileType {
+	if len(head) >= 0x40 {
+		off := int(binary.LittleEndian.Uint32(head[0x3c:]))
+		if off >= 0x40 && off+4 <= len(head) && string(head[off:off+4]) == "PE\x00\x00" {
+			return FileType{"application/vnd.microsoft.portable-executable", "binary", "PE32 executable"}
+		}
+	}
+	return FileType{"application/x-dosexec", "binary", "MS-DOS executable"}
+}
+
+// detectScript describe a script by the interpreter of its shebang line
+func detectScript(head []byte, charset string) FileType {
+	line := head[2:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return FileType{"text/plain", charset, charsetDescription(charset) + " text"}
+	}
+
+	interp := filepath.Base(fields[0])
+	if interp == "env" {
+		// #!/usr/bin/env -S python3 -u
+		for _, v := range fields[1:] {
+			if !strings.HasPrefix(v, "-") && !strings.Contains(v, "=") {
+				interp = filepath.Base(v)
+				break
+			}
+		}
+	}
+	// python3.9, perl5
+	name := strings.TrimRight(interp, "0123456789.")
+
+	if v, ok := interpreters[name]; ok {
+		return FileType{v[0], charset, v[1] + ", " + charsetDescription(charset) + " text executable"}
+	}
+	return FileType{"text/x-script", charset, interp + " script, " + charsetDescription(charset) + " text executable"}
+}
+
+// detectCharset guess the encoding of head, "binary" if it is not text
+func detectCharset(head []byte, truncated bool) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("\xef\xbb\xbf")):
+		return "utf-8"
+	case bytes.HasPrefix(head, []byte("\xff\xfe")):
+		return "utf-16le"
+	case bytes.HasPrefix(head, []byte("\xfe\xff")):
+		return "utf-16be"
+	}
+
+	ascii := true
+	for _, c := range head {
+		if c >= 0x80 {
+			ascii = false
+			continue
+		}
+		// control characters text does not have
+		if (c < 0x20 && !strings.ContainsRune("\t\n\r\f\b\v\x1b", rune(c))) || c == 0x7f {
+			return "binary"
+		}
+	}
+	if ascii {
+		return "us-ascii"
+	}
+
+	valid := head
+	if truncated {
+		// the last character may be cut short
+		for i := 0; i < utf8.UTFMax && len(valid) > 0 && !utf8.Valid(valid); i++ {
+			valid = valid[:len(valid)-1]
+		}
+	}
+	if utf8.Valid(valid) {
+		return "utf-8"
+	}
+	// C1 control characters are unlikely in Latin text
+	for _, c := range head {
+		if c >= 0x80 && c < 0xa0 {
+			return "binary"
+		}
+	}
+	return "iso-8859-1"
+}
+
+func charsetDescription(charset string) string {
+	switch charset {
+	case "us-ascii":
+		return "ASCII"
+	case "utf-8":
+		return "Unicode UTF-8"
+	case "utf-16le":
+		return "Unicode UTF-16, little-endian"
+	case "utf-16be":
+		return "Unicode UTF-16, big-endian"
+	case "iso-8859-1":
+		return "ISO-8859"
+	}
+	return charset
+}
@@ -0,0 +1,80 @@
+package fileutils
+
+import (
+	"bytes"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/marguerite/go-stdlib/dir"
+)
+
+func TestDetectTypeReader(t *testing.T) {
+	// e_lfanew at 0x3c points to the PE header at 0x80
+	pe := "MZ" + strings.Repeat("\x00", 0x3a) + "\x80" + strings.Repeat("\x00", 0x43) + "PE\x00\x00"
+	elf := []byte("\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x03\x00\x3e\x00")
+
+	tests := []struct {
+		content     string
+		mime        string
+		charset     string
+		description string
+	}{
+		{"", "inode/x-empty", "binary", "empty"},
+		{string(elf), "application/x-sharedlib", "binary", "ELF 64-bit LSB shared object"},
+		{pe, "application/vnd.microsoft.portable-executable", "binary", "PE32 executable"},
+		{"MZ\x90\x00", "application/x-dosexec", "binary", "MS-DOS executable"},
+		{"\x1f\x8b\x08\x00", "application/gzip", "binary", "gzip compressed data"},
+		{"\xfd7zXZ\x00\x00", "application/x-xz", "binary", "XZ compressed data"},
+		{"PK\x03\x04\x14\x00", "application/zip", "binary", "Zip archive data"},
+		{"\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", "image/png", "binary", "PNG image data"},
+		{"%PDF-1.7\n%\xe2\xe3", "application/pdf", "binary", "PDF document, version 1.7"},
+		{strings.Repeat("\x00", 257) + "ustar\x0000", "application/x-tar", "binary", "POSIX tar archive"},
+		{"#!/bin/sh\necho hi\n", "text/x-shellscript", "us-ascii", "POSIX shell script, ASCII text executable"},
+		{"#!/usr/bin/env -S python3.9 -u\nprint('ü')\n", "text/x-script.python", "utf-8", "Python script, Unicode UTF-8 text executable"},
+		{"#!/usr/bin/awk -f\n", "text/x-script", "us-ascii", "awk script, ASCII text executable"},
+		{"hello\n", "text/plain", "us-ascii", "ASCII text"},
+		{"caf\xc3\xa9\n", "text/plain", "utf-8", "Unicode UTF-8 text"},
+		{"caf\xe9\n", "text/plain", "iso-8859-1", "ISO-8859 text"},
+		{"\xff\xfeh\x00i\x00", "text/plain", "utf-16le", "Unicode UTF-16, little-endian text"},
+		{"\x00\x01\x02\x03", "application/octet-stream", "binary", "data"},
+	}
+	for _, v := range tests {
+		ft, err := DetectTypeReader(strings.NewReader(v.content))
+		correct := FileType{v.mime, v.charset, v.description}
+		if err != nil || ft != correct {
+			t.Errorf("[fileutils]DetectTypeReader test failed for %q, expecting %v, got %v, err %v", v.content, correct, ft, err)
+		}
+	}
+
+	// a UTF-8 character cut at the end of what is read
+	b := append(bytes.Repeat([]byte("a"), sniffSize-1), "é"...)
+	if ft, err := DetectTypeReader(bytes.NewReader(b)); err != nil || ft.Charset != "utf-8" {
+		t.Errorf("[fileutils]DetectTypeReader test failed, expecting utf-8, got %v, err %v", ft, err)
+	}
+}
+
+func TestDetectType(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "data.json", Content: "{}\n"},
+		{Path: "dir", Mode: os.ModeDir},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	if ft, err := DetectType(tree.Path("data.json")); err != nil || ft.MIME != "application/json" || !ft.IsText() {
+		t.Errorf("[fileutils]DetectType test failed, expecting application/json, got %v, err %v", ft, err)
+	}
+	if ft, err := DetectType(tree.Path("dir")); err != nil || ft.MIME != "inode/directory" {
+		t.Errorf("[fileutils]DetectType test failed, expecting inode/directory, got %v, err %v", ft, err)
+	}
+	if runtime.GOOS == "linux" {
+		// the test binary
+		if ft, err := DetectType(os.Args[0]); err != nil || !ft.IsELF() {
+			t.Errorf("[fileutils]DetectType test failed, expecting an ELF executable, got %v, err %v", ft, err)
+		}
+	}
+}
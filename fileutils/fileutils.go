@@ -2,47 +2,53 @@ package fileutils
 
 import (
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/marguerite/go-stdlib/dir"
-	"github.com/marguerite/go-stdlib/extglob"
-	"github.com/marguerite/go-stdlib/internal"
+	"github.com/marguerite/go-stdlib/vfs"
 )
 
-//Touch touch a file
+//Touch touch a file: create it along with its missing parent directories,
+// or set its access and modification times to now. see TouchWithOptions for more.
 func Touch(path string) error {
-	_, err := os.Stat(path)
+	return TouchFS(vfs.OS, path)
+}
 
-	if err == nil {
-		return err
+// TouchFS Touch in fsys
+func TouchFS(fsys vfs.FS, path string) error {
+	now := time.Now()
+	if fsys == vfs.OS {
+		return touch(path, now, now, TouchOptions{})
 	}
+
+	_, err := fsys.Stat(path)
 	if os.IsNotExist(err) {
-		// create containing directory
-		parent := filepath.Dir(path)
-		err = dir.MkdirP(parent)
+		err = fsys.MkdirAll(filepath.Dir(path), os.ModePerm)
 		if err != nil {
 			return err
 		}
-		_, err = os.Create(path)
+		f, err := fsys.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0666)
 		if err != nil {
 			return err
 		}
+		err = f.Close()
+		if err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
 	}
-	return err
+	return fsys.Chtimes(path, now, now)
 }
 
 //cp copy a single file to another file or directory
-func cp(source, destination, original string) error {
+func cp(fsys vfs.FS, source, destination, original string) error {
 	// source always exists and can be file only
-	s, err := ioutil.ReadFile(source)
-	if err != nil {
-		return err
-	}
-
 	// destination can be non-existent target, file or directory.
-	di, err := os.Stat(destination)
+	di, err := fsys.Stat(destination)
 
 	if err != nil && !os.IsNotExist(err) {
 		return err
@@ -54,24 +60,32 @@ func cp(source, destination, original string) error {
 	}
 
 	if os.IsNotExist(err) {
-		err = dir.MkdirP(filepath.Dir(destination))
+		// MkdirP fails on an existing parent
+		err = fsys.MkdirAll(filepath.Dir(destination), os.ModePerm)
 		if err != nil {
 			return err
 		}
 	}
 
-	fi, _ := os.Stat(source)
-	err = ioutil.WriteFile(destination, s, fi.Mode())
+	fi, err := fsys.Stat(source)
+	if err != nil {
+		return err
+	}
+	// stream instead of reading the whole source into memory
+	if fsys == vfs.OS {
+		err = newCopier(CopyOptions{}).content(source, destination, fi)
+	} else {
+		err = contentFS(fsys, source, destination, fi)
+	}
 	if err != nil {
 		return err
 	}
 
 	if len(original) > 0 {
-		err := os.RemoveAll(original)
-		if err != nil {
-			return err
-		}
-		err = os.Symlink(destination, original)
+		// point the original symlink to the copy, without a moment it is missing
+		err := replaceWith(fsys, original, func(tmp string) error {
+			return fsys.Symlink(destination, tmp)
+		})
 		if err != nil {
 			return err
 		}
@@ -79,23 +93,51 @@ func cp(source, destination, original string) error {
 	return nil
 }
 
-func copy(source, destination string, fn func(s, d, o string) error) error {
-	// check source status
-	si, _ := os.Stat(source)
+// contentFS copy the content of source to target in fsys, which is created with
+// the permission of source, fi, or truncated
+func contentFS(fsys vfs.FS, source, target string, fi os.FileInfo) error {
+	in, err := fsys.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := fsys.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	err1 := out.Close()
+	if err != nil {
+		fsys.Remove(target)
+		return err
+	}
+	return err1
+}
+
+func copy(fsys vfs.FS, source, destination string, fn func(s, d, o string) error) error {
+	// check source status, lstat or symlinks are never seen
+	si, err := fsys.Lstat(source)
+	if err != nil {
+		return err
+	}
 
 	// source is a symlink, copy its original content
 	if si.Mode()&os.ModeSymlink != 0 {
-		link, err := dir.FollowSymlink(source)
+		link, err := dir.FollowSymlinkFS(fsys, source)
+		if err != nil {
+			return err
+		}
+		si, err = fsys.Stat(link)
 		if err != nil {
 			return err
 		}
-		tmp, _ := os.Stat(link)
-		si = tmp
 		source = link
 	}
 
 	// check destination status
-	di, err := os.Stat(destination)
+	di, err := fsys.Stat(destination)
 	// destination can be non-existent target
 	if err != nil && !os.IsNotExist(err) {
 		return err
@@ -105,7 +147,7 @@ func copy(source, destination string, fn func(s, d, o string) error) error {
 
 	if err == nil && di.Mode()&os.ModeSymlink != 0 {
 		// copy to its original file and symlink back
-		link, err := dir.FollowSymlink(destination)
+		link, err := dir.FollowSymlinkFS(fsys, destination)
 		if err != nil {
 			return err
 		}
@@ -124,31 +166,44 @@ func copy(source, destination string, fn func(s, d, o string) error) error {
 	// copy directory
 	if si.Mode().IsDir() {
 		// files can be symlink or actual file
-		files, err := dir.Ls(source, true, true)
+		files, err := dir.LsFS(fsys, source, true, true)
 		if err != nil {
 			return err
 		}
 
 		for _, f := range files {
-			fi, err := os.Stat(f)
+			fi, err := fsys.Lstat(f)
 			if err != nil {
 				// skipped
 				continue
 			}
 
+			// keep hierarchy, what Ls lists must not lead out of source
+			p, err := filepath.Rel(source, filepath.Dir(f))
+			if err != nil || !isWithin(source, f) {
+				return &os.PathError{Op: "copy", Path: f, Err: dir.ErrEscapesBase}
+			}
+			dest := filepath.Join(destination, p, filepath.Base(f))
+
 			if fi.Mode().IsDir() {
-				// skip directory, they'll be created in cp function
+				// create it here, or empty directories are lost
+				err = fsys.MkdirAll(dest, os.ModePerm)
+				if err != nil {
+					return err
+				}
 				continue
 			}
 
-			// keep hierarchy
-			p, _ := filepath.Rel(source, filepath.Dir(f))
-			dest := filepath.Join(destination, p, filepath.Base(f))
-
 			// f is a symlink, copy its original content
 			if fi.Mode()&os.ModeSymlink != 0 {
-				link, err := dir.FollowSymlink(f)
+				link, err := dir.FollowSymlinkFS(fsys, f)
 				if err != nil {
+					// dangling
+					continue
+				}
+				li, err := fsys.Stat(link)
+				if err != nil || li.IsDir() {
+					// symlinks to directories are not followed
 					continue
 				}
 				f = link
@@ -166,13 +221,21 @@ func copy(source, destination string, fn func(s, d, o string) error) error {
 
 // Copy like Linux's cp command, copy a file/dirctory to another place.
 func Copy(src, dest string) error {
-	sources, err := extglob.Expand(internal.Str2bytes(src))
+	return CopyFS(vfs.OS, src, dest)
+}
+
+// CopyFS Copy in fsys
+func CopyFS(fsys vfs.FS, src, dest string) error {
+	sources, err := vfs.Expand(fsys, src)
 	if err != nil {
 		return err
 	}
+	fn := func(s, d, o string) error {
+		return cp(fsys, s, d, o)
+	}
 	// sources are always valid files, the check is in extglob's validFunc
 	for _, v := range sources {
-		err1 := copy(v, dest, cp)
+		err1 := copy(fsys, v, dest, fn)
 		if err1 != nil {
 			return err1
 		}
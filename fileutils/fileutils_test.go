@@ -1,13 +1,101 @@
 package fileutils
 
 import (
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/marguerite/go-stdlib/dir"
+	"github.com/marguerite/go-stdlib/vfs"
 )
 
 func TestCopy(t *testing.T) {
 	fn := func(s, d, o string) error { return nil }
-	err := copy("fileutils.go", "fileutils.go.new", fn)
+	err := copy(vfs.OS, "fileutils.go", "fileutils.go.new", fn)
 	if err != nil {
 		t.Error("fileutils.Copy test failed")
 	}
 }
+
+func TestCopyDirectory(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "src/empty", Mode: os.ModeDir | 0755},
+		{Path: "src/a", Mode: 0644, Content: "a"},
+		{Path: "src/link", Mode: os.ModeSymlink, Target: "a"},
+		{Path: "src/dangling", Mode: os.ModeSymlink, Target: "missing"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	err = Copy(tree.Path("src"), tree.Path("dst"))
+	correct := dir.TreeSpec{
+		{Path: "a", Mode: 0644, Content: "a"},
+		{Path: "empty", Mode: os.ModeDir | 0755},
+		{Path: "link", Mode: 0644, Content: "a"},
+	}
+	spec, err1 := (&dir.Tree{Root: tree.Path("dst")}).Snapshot()
+	if err != nil || err1 != nil || !reflect.DeepEqual(spec, correct) {
+		t.Errorf("[fileutils]Copy test failed, expecting %v, got %v, err %v %v", correct, spec, err, err1)
+	}
+}
+
+func TestCopyFS(t *testing.T) {
+	m := vfs.NewMemFS()
+	m.MkdirAll("/src/empty", 0755)
+	f, _ := m.OpenFile("/src/a", os.O_WRONLY|os.O_CREATE, 0600)
+	io.WriteString(f, "a")
+	f.Close()
+	m.Symlink("a", "/src/link")
+	m.Symlink("missing", "/src/dangling")
+
+	err := CopyFS(m, "/src", "/dst")
+	if err != nil {
+		t.Fatalf("[fileutils]CopyFS test failed: %v", err)
+	}
+	for p, mode := range map[string]os.FileMode{"/dst/a": 0600, "/dst/link": 0600, "/dst/empty": os.ModeDir | 0777} {
+		fi, err := m.Lstat(p)
+		if err != nil || fi.Mode() != mode {
+			t.Errorf("[fileutils]CopyFS test failed, expecting %s with mode %v, got %v, err %v", p, mode, fi, err)
+		}
+	}
+	if _, err := m.Lstat("/dst/dangling"); !os.IsNotExist(err) {
+		t.Errorf("[fileutils]CopyFS test failed, expecting dangling symlinks skipped, got err %v", err)
+	}
+
+	// copying to a symlink copies to its target and keeps the symlink
+	m.Symlink("/dst/a", "/dst/b")
+	f, _ = m.Create("/c")
+	io.WriteString(f, "c")
+	f.Close()
+	err = CopyFS(m, "/c", "/dst/b")
+	f, _ = m.Open("/dst/a")
+	b, _ := ioutil.ReadAll(f)
+	f.Close()
+	target, err1 := m.Readlink("/dst/b")
+	if err != nil || err1 != nil || string(b) != "c" || target != "/dst/a" {
+		t.Errorf("[fileutils]CopyFS test failed, expecting /dst/a with content c through /dst/b, got %q via %s, err %v %v", b, target, err, err1)
+	}
+}
+
+func TestTouchFS(t *testing.T) {
+	m := vfs.NewMemFS()
+	if err := TouchFS(m, "/a/b/c"); err != nil {
+		t.Fatalf("[fileutils]TouchFS test failed: %v", err)
+	}
+	fi, err := m.Stat("/a/b/c")
+	if err != nil || !fi.Mode().IsRegular() || fi.Size() != 0 {
+		t.Errorf("[fileutils]TouchFS test failed, expecting an empty /a/b/c, got %v, err %v", fi, err)
+	}
+
+	old := fi.ModTime().Add(-time.Hour)
+	m.Chtimes("/a/b/c", old, old)
+	TouchFS(m, "/a/b/c")
+	if fi, err := m.Stat("/a/b/c"); err != nil || !fi.ModTime().After(old) {
+		t.Errorf("[fileutils]TouchFS test failed, expecting mtime after %v, got %v, err %v", old, fi.ModTime(), err)
+	}
+}
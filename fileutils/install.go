@@ -0,0 +1,221 @@
+package fileutils
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/marguerite/go-stdlib/dir"
+	"github.com/marguerite/go-stdlib/internal"
+)
+
+// InstallOptions options for Install
+type InstallOptions struct {
+	// Mode the permission of installed files, 0755 if zero, like `install -m`
+	Mode os.FileMode
+	// Owner/Group name or numeric id to give installed files, empty to keep, like `install -o` and `-g`
+	Owner string
+	Group string
+	// CreateLeadingDirs create the missing parent directories of dest, like `install -D`.
+	// with several sources dest itself is created, like `install -D -t`.
+	CreateLeadingDirs bool
+	// PreserveTimestamps give installed files the access and modification times of their sources, like `install -p`
+	PreserveTimestamps bool
+	// Compare leave a destination alone when its content, mode and owner already match, like `install -C`
+	Compare bool
+	// Strip strip the symbol tables of installed files with strip(1), or the program
+	// named by the STRIPPROG environment variable, like `install -s`. files that
+	// are not ELF objects, like scripts, are installed as they are.
+	Strip bool
+	// Backup back up an existing destination before replacing it
+	Backup BackupMode
+	// Suffix the suffix of simple backups, "~" if empty
+	Suffix string
+}
+
+// Install like Linux's install command, copy files matching the extglob pattern
+// src to dest and set their mode and owner. if dest is an existing directory,
+// files are installed into it. each file is prepared under a temporary name
+// next to its destination and renamed into place, so a destination is never
+// seen half written.
+func Install(src, dest string, opts InstallOptions) error {
+	sources, err := expandPattern(src)
+	if err != nil {
+		return err
+	}
+
+	uid, gid, err := internal.LookupOwner(opts.Owner, opts.Group)
+	if err != nil {
+		return err
+	}
+
+	if len(sources) > 1 {
+		if opts.CreateLeadingDirs {
+			_, err := dir.MkdirPWithOptions(dest, dir.MkdirOptions{Mode: 0755})
+			if err != nil {
+				return err
+			}
+		}
+		if di, err := os.Stat(dest); err != nil || !di.IsDir() {
+			return fmt.Errorf("target %s is not a directory", dest)
+		}
+	}
+
+	for _, v := range sources {
+		target := dest
+		if di, err := os.Stat(dest); err == nil && di.IsDir() {
+			target = filepath.Join(dest, filepath.Base(v))
+		} else if opts.CreateLeadingDirs {
+			_, err := dir.MkdirPWithOptions(filepath.Dir(target), dir.MkdirOptions{Mode: 0755})
+			if err != nil {
+				return err
+			}
+		}
+
+		err := install(v, target, uid, gid, opts)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func install(source, target string, uid, gid int, opts InstallOptions) (err error) {
+	si, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if si.IsDir() {
+		return fmt.Errorf("cannot install directory %s", source)
+	}
+
+	ti, err := os.Lstat(target)
+	switch {
+	case err == nil:
+		if ti.IsDir() {
+			return fmt.Errorf("cannot overwrite directory %s with non-directory %s", target, source)
+		}
+		if os.SameFile(si, ti) {
+			return fmt.Errorf("%s and %s are the same file", source, target)
+		}
+	case os.IsNotExist(err):
+		ti = nil
+	default:
+		return err
+	}
+
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := createAtomic(target)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.abort()
+		}
+	}()
+
+	err = newCopier(CopyOptions{}).transfer(tmp.File, in, si)
+	if err != nil {
+		return err
+	}
+	err = tmp.close()
+	if err != nil {
+		return err
+	}
+
+	// the rest works by name, as strip(1) replaces the file
+	if opts.Strip {
+		var t FileType
+		t, err = DetectType(tmp.Name())
+		if err != nil {
+			return err
+		}
+		if t.IsELF() {
+			err = strip(tmp.Name())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if uid != -1 || gid != -1 {
+		err = os.Chown(tmp.Name(), uid, gid)
+		if err != nil {
+			return err
+		}
+	}
+
+	mode := opts.Mode
+	if mode == 0 {
+		mode = 0755
+	}
+	// after chown, which clears the setuid bits
+	err = os.Chmod(tmp.Name(), mode)
+	if err != nil {
+		return err
+	}
+
+	if opts.PreserveTimestamps {
+		err = os.Chtimes(tmp.Name(), internal.Atime(si), si.ModTime())
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.Compare && ti != nil {
+		same, err := sameInstall(tmp.Name(), target, ti)
+		if err != nil {
+			return err
+		}
+		if same {
+			tmp.abort()
+			return nil
+		}
+	}
+
+	return tmp.commit(opts.Backup, opts.Suffix)
+}
+
+// strip run strip(1) or $STRIPPROG on path, and sync what it wrote
+func strip(path string) error {
+	prog := os.Getenv("STRIPPROG")
+	if len(prog) == 0 {
+		prog = "strip"
+	}
+	out, err := exec.Command(prog, path).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %v: %s", prog, path, err, out)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// sameInstall whether the existing target, whose FileInfo is ti, has the
+// content, mode and owner of the prepared file staged
+func sameInstall(staged, target string, ti os.FileInfo) (bool, error) {
+	fi, err := os.Stat(staged)
+	if err != nil {
+		return false, err
+	}
+	if !ti.Mode().IsRegular() || ti.Mode() != fi.Mode() || ti.Size() != fi.Size() {
+		return false, nil
+	}
+	uid1, gid1, _ := internal.StatOwner(fi)
+	uid2, gid2, _ := internal.StatOwner(ti)
+	if uid1 != uid2 || gid1 != gid2 {
+		return false, nil
+	}
+	return sameContent(staged, target)
+}
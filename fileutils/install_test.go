@@ -0,0 +1,133 @@
+package fileutils
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/marguerite/go-stdlib/dir"
+)
+
+func TestInstall(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "src/app.conf", Mode: 0600, Content: "conf"},
+		{Path: "src/app.desktop", Mode: 0600, Content: "desktop"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	err = Install(tree.Path("src/app.conf"), tree.Path("usr/etc/app/app.conf"), InstallOptions{Mode: 0644, CreateLeadingDirs: true})
+	if err != nil {
+		t.Fatalf("[fileutils]Install test failed, expecting nil, got %v", err)
+	}
+	err = Install(tree.Path("src/app.*"), tree.Path("usr/share/app"), InstallOptions{Mode: 0644, CreateLeadingDirs: true})
+	if err != nil {
+		t.Fatalf("[fileutils]Install test failed, expecting nil, got %v", err)
+	}
+
+	correct := dir.TreeSpec{
+		{Path: "etc", Mode: os.ModeDir | 0755},
+		{Path: "etc/app", Mode: os.ModeDir | 0755},
+		{Path: "etc/app/app.conf", Mode: 0644, Content: "conf"},
+		{Path: "share", Mode: os.ModeDir | 0755},
+		{Path: "share/app", Mode: os.ModeDir | 0755},
+		{Path: "share/app/app.conf", Mode: 0644, Content: "conf"},
+		{Path: "share/app/app.desktop", Mode: 0644, Content: "desktop"},
+	}
+	spec, err := (&dir.Tree{Root: tree.Path("usr")}).Snapshot()
+	if err != nil || !reflect.DeepEqual(spec, correct) {
+		t.Errorf("[fileutils]Install test failed, expecting %v, got %v, err %v", correct, spec, err)
+	}
+}
+
+func TestInstallCompare(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "app", Mode: 0755, Content: "binary"},
+		{Path: "bin/app", Mode: 0755, Content: "binary"},
+		{Path: "bin/old", Mode: 0755, Content: "old"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, v := range []string{"bin/app", "bin/old"} {
+		err = os.Chtimes(tree.Path(v), past, past)
+		if err != nil {
+			t.Fatalf("[fileutils]Chtimes failed: %v", err)
+		}
+	}
+
+	for _, v := range []string{"bin/app", "bin/old"} {
+		err = Install(tree.Path("app"), tree.Path(v), InstallOptions{Compare: true})
+		if err != nil {
+			t.Fatalf("[fileutils]Install test failed, expecting nil, got %v", err)
+		}
+	}
+
+	fi, err := os.Stat(tree.Path("bin/app"))
+	if err != nil || !fi.ModTime().Equal(past) {
+		t.Errorf("[fileutils]Install test failed, expecting unchanged mtime %v, got %v, err %v", past, fi.ModTime(), err)
+	}
+
+	correct := dir.TreeSpec{
+		{Path: "app", Mode: 0755, Content: "binary"},
+		{Path: "old", Mode: 0755, Content: "binary"},
+	}
+	spec, err := (&dir.Tree{Root: tree.Path("bin")}).Snapshot()
+	if err != nil || !reflect.DeepEqual(spec, correct) {
+		t.Errorf("[fileutils]Install test failed, expecting %v, got %v, err %v", correct, spec, err)
+	}
+}
+
+func TestInstallPreserveTimestamps(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "app", Mode: 0644, Content: "binary"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	err = os.Chtimes(tree.Path("app"), past, past)
+	if err != nil {
+		t.Fatalf("[fileutils]Chtimes failed: %v", err)
+	}
+
+	err = Install(tree.Path("app"), tree.Path("app.installed"), InstallOptions{PreserveTimestamps: true})
+	if err != nil {
+		t.Fatalf("[fileutils]Install test failed, expecting nil, got %v", err)
+	}
+	fi, err := os.Stat(tree.Path("app.installed"))
+	if err != nil || !fi.ModTime().Equal(past) || fi.Mode() != 0755 {
+		t.Errorf("[fileutils]Install test failed, expecting mode 0755 and mtime %v, got %v, err %v", past, fi, err)
+	}
+
+	err = Install(tree.Path("app"), tree.Path("app"), InstallOptions{})
+	if err == nil {
+		t.Errorf("[fileutils]Install test failed, expecting an error installing a file over itself, got nil")
+	}
+}
+
+func TestInstallStripScript(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "app.sh", Content: "#!/bin/sh\n"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	// fails if it is run at all
+	os.Setenv("STRIPPROG", "false")
+	defer os.Unsetenv("STRIPPROG")
+	err = Install(tree.Path("app.sh"), tree.Path("bin/app"), InstallOptions{Strip: true, CreateLeadingDirs: true})
+	if err != nil {
+		t.Errorf("[fileutils]Install test failed, expecting scripts not stripped, got %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package fileutils
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/marguerite/go-stdlib/dir"
+	"github.com/marguerite/go-stdlib/extglob"
+	"github.com/marguerite/go-stdlib/internal"
+	"github.com/marguerite/go-stdlib/vfs"
+)
+
+// LinkOptions options for Ln
+type LinkOptions struct {
+	// Symbolic make symlinks instead of hard links, like `ln -s`
+	Symbolic bool
+	// Force replace an existing destination, like `ln -f`
+	Force bool
+	// Relative make symlinks relative to the link location, like `ln -sr`
+	Relative bool
+	// NoDereference treat a destination symlinking to a directory as a file, like `ln -n`
+	NoDereference bool
+	// Backup back up an existing destination before replacing it, implies Force
+	Backup BackupMode
+	// Suffix the suffix of simple backups, "~" if empty
+	Suffix string
+}
+
+// Ln like Linux's ln command, link files matching the extglob pattern src to dest.
+// if dest is an existing directory, links are made inside it. a src that is not a
+// pattern is used as is, so symlinks to missing targets can be made.
+// an existing destination is replaced by renaming the new link over it, so
+// readers never see a missing path.
+func Ln(src, dest string, opts LinkOptions) error {
+	sources := []string{src}
+	if extglob.IsPattern(internal.Str2bytes(src), true) {
+		var err error
+		sources, err = extglob.Expand(internal.Str2bytes(src))
+		if err != nil {
+			return err
+		}
+		if len(sources) == 0 {
+			return &os.PathError{Op: "link", Path: src, Err: os.ErrNotExist}
+		}
+	}
+
+	isDir := false
+	if di, err := statLink(dest, opts.NoDereference); err == nil && di.IsDir() {
+		isDir = true
+	}
+	if len(sources) > 1 && !isDir {
+		return fmt.Errorf("target %s is not a directory", dest)
+	}
+
+	for _, v := range sources {
+		target := dest
+		if isDir {
+			target = filepath.Join(dest, filepath.Base(v))
+		}
+		err := ln(v, target, opts)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statLink lstat when nofollow, stat otherwise
+func statLink(path string, nofollow bool) (os.FileInfo, error) {
+	if nofollow {
+		return os.Lstat(path)
+	}
+	return os.Stat(path)
+}
+
+func ln(source, target string, opts LinkOptions) error {
+	oldname := source
+	if opts.Symbolic && opts.Relative {
+		rel, err := relativeTarget(source, target)
+		if err != nil {
+			return err
+		}
+		oldname = rel
+	}
+
+	ti, err := os.Lstat(target)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		return link(oldname, target, opts.Symbolic)
+	}
+
+	if !opts.Force && opts.Backup == NoBackup {
+		return &os.LinkError{Op: "link", Old: source, New: target, Err: os.ErrExist}
+	}
+	if ti.IsDir() {
+		return fmt.Errorf("cannot overwrite directory %s", target)
+	}
+	if !opts.Symbolic {
+		if si, err := os.Stat(source); err == nil && os.SameFile(si, ti) {
+			return fmt.Errorf("%s and %s are the same file", source, target)
+		}
+	}
+
+	if opts.Backup != NoBackup {
+		name, err := backupName(target, opts.Backup, opts.Suffix)
+		if err != nil {
+			return err
+		}
+		err = removeNonDir(name)
+		if err != nil {
+			return err
+		}
+		// a hard link keeps target in place until it is replaced
+		err = os.Link(target, name)
+		if err != nil {
+			return err
+		}
+	}
+	return replaceWithLink(oldname, target, opts.Symbolic)
+}
+
+func link(oldname, newname string, symbolic bool) error {
+	if symbolic {
+		return os.Symlink(oldname, newname)
+	}
+	return os.Link(oldname, newname)
+}
+
+// replaceWithLink atomically replace newname with a link to oldname,
+// by linking a temporary name in the same directory and renaming it over newname
+func replaceWithLink(oldname, newname string, symbolic bool) error {
+	return replaceWith(vfs.OS, newname, func(tmp string) error {
+		return link(oldname, tmp, symbolic)
+	})
+}
+
+// replaceWith atomically replace newname in fsys with what mk creates at the
+// temporary name it is given, mk fails with os.ErrExist if that name is taken
+func replaceWith(fsys vfs.FS, newname string, mk func(tmp string) error) error {
+	base := filepath.Join(filepath.Dir(newname), "."+filepath.Base(newname)+".ln")
+	for {
+		tmp := base + strconv.Itoa(rand.Int())
+		err := mk(tmp)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = fsys.Rename(tmp, newname)
+		if err != nil {
+			fsys.Remove(tmp)
+		}
+		return err
+	}
+}
+
+// relativeTarget the symlink target of source relative to the directory of dest,
+// both directories are canonicalized but source itself is not dereferenced
+func relativeTarget(source, dest string) (string, error) {
+	from, err := dir.Realpath(filepath.Dir(dest), dir.CanonicalizeMissing)
+	if err != nil {
+		return "", err
+	}
+	to, err := dir.Realpath(filepath.Dir(source), dir.CanonicalizeMissing)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(from, filepath.Join(to, filepath.Base(source)))
+}
@@ -0,0 +1,58 @@
+package fileutils
+
+import (
+	"os"
+	"testing"
+
+	"github.com/marguerite/go-stdlib/dir"
+)
+
+func TestLn(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "lib/libfoo.so.1", Content: "v1"},
+		{Path: "lib/libfoo.so.2", Content: "v2"},
+		{Path: "bin/a", Content: "a"},
+		{Path: "bin/b", Content: "b"},
+		{Path: "usr/bin", Mode: os.ModeDir},
+		{Path: "current", Mode: os.ModeSymlink, Target: "usr/bin"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	err = Ln(tree.Path("lib/libfoo.so.1"), tree.Path("lib/libfoo.so"), LinkOptions{Symbolic: true, Relative: true})
+	if link, _ := os.Readlink(tree.Path("lib/libfoo.so")); err != nil || link != "libfoo.so.1" {
+		t.Errorf("[fileutils]Ln relative test failed, expecting libfoo.so.1, got %s, err %v", link, err)
+	}
+
+	err = Ln(tree.Path("lib/libfoo.so.2"), tree.Path("lib/libfoo.so"), LinkOptions{Symbolic: true, Relative: true})
+	if !os.IsExist(err) {
+		t.Errorf("[fileutils]Ln test failed, expecting exist error without Force, got %v", err)
+	}
+
+	err = Ln(tree.Path("lib/libfoo.so.2"), tree.Path("lib/libfoo.so"), LinkOptions{Symbolic: true, Relative: true, Backup: SimpleBackup})
+	if link, _ := os.Readlink(tree.Path("lib/libfoo.so")); err != nil || link != "libfoo.so.2" {
+		t.Errorf("[fileutils]Ln Backup test failed, expecting libfoo.so.2, got %s, err %v", link, err)
+	}
+	if link, _ := os.Readlink(tree.Path("lib/libfoo.so~")); link != "libfoo.so.1" {
+		t.Errorf("[fileutils]Ln Backup test failed, expecting backup to libfoo.so.1, got %s", link)
+	}
+
+	// hard links of several sources into a directory through a symlink
+	err = Ln(tree.Path("bin/*"), tree.Path("current"), LinkOptions{})
+	if err != nil {
+		t.Fatalf("[fileutils]Ln test failed, expecting nil, got %v", err)
+	}
+	fi, _ := os.Stat(tree.Path("bin/a"))
+	fi1, err := os.Lstat(tree.Path("usr/bin/a"))
+	if err != nil || !os.SameFile(fi, fi1) {
+		t.Errorf("[fileutils]Ln hard link test failed, expecting usr/bin/a to be bin/a, err %v", err)
+	}
+
+	// NoDereference replaces the symlink to a directory itself
+	err = Ln("lib", tree.Path("current"), LinkOptions{Symbolic: true, Force: true, NoDereference: true})
+	if link, _ := os.Readlink(tree.Path("current")); err != nil || link != "lib" {
+		t.Errorf("[fileutils]Ln NoDereference test failed, expecting lib, got %s, err %v", link, err)
+	}
+}
@@ -0,0 +1,101 @@
+package fileutils
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/marguerite/go-stdlib/extglob"
+	"github.com/marguerite/go-stdlib/internal"
+)
+
+const sep = string(extglob.PATH_SEPARATOR)
+
+// matchPath whether the relative path rel matches one of the extglob patterns,
+// like `rsync --exclude` does: a pattern without a slash matches the name of rel
+// at any depth, one with a slash matches rel from the top. a pattern ending in
+// a slash only matches directories.
+func matchPath(patterns []string, rel string, isDir bool) (bool, error) {
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	for _, p := range patterns {
+		p = filepath.ToSlash(p)
+		if strings.HasSuffix(p, "/") {
+			if !isDir {
+				continue
+			}
+			p = strings.TrimRight(p, "/")
+		}
+		name := rel
+		if !strings.Contains(p, "/") {
+			name = rel[strings.LastIndexByte(rel, '/')+1:]
+		}
+
+		t := newNameTree(name, isDir)
+		paths, err := extglob.ExpandFunc(internal.Str2bytes(sep+strings.Replace(strings.TrimPrefix(p, "/"), "/", sep, -1)), t.list, t.valid)
+		if err != nil {
+			return false, err
+		}
+		for _, v := range paths {
+			if strings.TrimSuffix(v, sep) == t.nodes[len(t.nodes)-1] {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// nameTree the tree of a single slash separated relative name, which extglob can
+// expand patterns in. it is rooted at "" with absolute paths, like archive members.
+type nameTree struct {
+	// nodes the root, the parents of the name and the name itself
+	nodes []string
+	dir   bool
+}
+
+func newNameTree(name string, isDir bool) *nameTree {
+	t := &nameTree{nodes: []string{""}, dir: isDir}
+	p := ""
+	for _, v := range strings.Split(name, "/") {
+		p += sep + v
+		t.nodes = append(t.nodes, p)
+	}
+	return t
+}
+
+// index the position of p in the nodes, -1 if p is not in the tree
+func (t *nameTree) index(p string) int {
+	p = strings.TrimSuffix(p, sep)
+	for i, v := range t.nodes {
+		if v == p {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *nameTree) list(p string, globalstar, tailing bool) ([]string, error) {
+	i := t.index(p)
+	last := len(t.nodes) - 1
+	switch {
+	case i < 0:
+		return nil, nil
+	case i == last && !t.dir:
+		return []string{t.nodes[i]}, nil
+	case !globalstar:
+		if i == last {
+			return nil, nil
+		}
+		return []string{t.nodes[i+1]}, nil
+	}
+
+	var paths []string
+	for _, v := range t.nodes[i:] {
+		if !tailing || v != t.nodes[last] || t.dir {
+			paths = append(paths, v)
+		}
+	}
+	return paths, nil
+}
+
+func (t *nameTree) valid(p string) bool {
+	return t.index(p) >= 0
+}
@@ -0,0 +1,210 @@
+package fileutils
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// the unix permission bits, os.FileMode keeps setuid, setgid and sticky elsewhere
+const (
+	modeSetuid = 04000
+	modeSetgid = 02000
+	modeSticky = 01000
+	modeUser   = 04700
+	modeGroup  = 02070
+	modeOther  = 01007
+	modeAll    = 07777
+)
+
+// ModeSpec a parsed chmod(1) mode, either octal like "0755" or symbolic like
+// "u+rwX,go-w", "=r", "g=u" or "+t"
+type ModeSpec struct {
+	octal   bool
+	digits  int
+	value   uint32
+	clauses []modeClause
+}
+
+type modeClause struct {
+	// who the u, g, o and a letters as bits, zero when no letter is given
+	who uint32
+	ops []modeOp
+}
+
+type modeOp struct {
+	op byte
+	// perm the r, w, x, s and t letters as bits for all classes
+	perm uint32
+	// conditional X, execute only for directories or files executable by someone
+	condX bool
+	// copy the u, g or o letter to copy the permission of, 0 if none
+	copy byte
+}
+
+// ParseMode parse a chmod(1) mode string
+func ParseMode(s string) (*ModeSpec, error) {
+	if len(s) == 0 {
+		return nil, fmt.Errorf("invalid mode %q", s)
+	}
+
+	if s[0] >= '0' && s[0] <= '7' {
+		v, err := strconv.ParseUint(s, 8, 32)
+		if err != nil || v > modeAll {
+			return nil, fmt.Errorf("invalid mode %q", s)
+		}
+		return &ModeSpec{octal: true, digits: len(s), value: uint32(v)}, nil
+	}
+
+	spec := &ModeSpec{}
+	for _, c := range strings.Split(s, ",") {
+		var clause modeClause
+		i := 0
+	who:
+		for ; i < len(c); i++ {
+			switch c[i] {
+			case 'u':
+				clause.who |= modeUser
+			case 'g':
+				clause.who |= modeGroup
+			case 'o':
+				clause.who |= modeOther
+			case 'a':
+				clause.who |= modeAll
+			default:
+				break who
+			}
+		}
+		if i == len(c) {
+			return nil, fmt.Errorf("invalid mode %q: missing operator in %q", s, c)
+		}
+
+		for i < len(c) {
+			op := modeOp{op: c[i]}
+			if op.op != '+' && op.op != '-' && op.op != '=' {
+				return nil, fmt.Errorf("invalid mode %q: unknown operator %q", s, c[i])
+			}
+			i++
+			if i < len(c) && (c[i] == 'u' || c[i] == 'g' || c[i] == 'o') {
+				op.copy = c[i]
+				i++
+			} else {
+			perm:
+				for ; i < len(c); i++ {
+					switch c[i] {
+					case 'r':
+						op.perm |= 0444
+					case 'w':
+						op.perm |= 0222
+					case 'x':
+						op.perm |= 0111
+					case 'X':
+						op.condX = true
+					case 's':
+						op.perm |= modeSetuid | modeSetgid
+					case 't':
+						op.perm |= modeSticky
+					case '+', '-', '=':
+						break perm
+					default:
+						return nil, fmt.Errorf("invalid mode %q: unknown permission %q", s, c[i])
+					}
+				}
+			}
+			clause.ops = append(clause.ops, op)
+		}
+		spec.clauses = append(spec.clauses, clause)
+	}
+	return spec, nil
+}
+
+// Apply compute the new mode of a file whose current mode is mode. umask
+// restricts the clauses without u, g, o or a letters, like chmod(1) does.
+// like GNU chmod, directories keep their setuid and setgid bits unless changed explicitly.
+func (m *ModeSpec) Apply(mode, umask os.FileMode) os.FileMode {
+	cur := toUnixMode(mode)
+	dir := mode.IsDir()
+
+	if m.octal {
+		v := m.value
+		if dir && m.digits < 5 {
+			v |= cur & (modeSetuid | modeSetgid)
+		}
+		return fromUnixMode(mode, v)
+	}
+
+	mask := toUnixMode(umask) & 0777
+	for _, clause := range m.clauses {
+		who, protected := clause.who, uint32(0)
+		if who == 0 {
+			who, protected = modeAll, mask
+		}
+
+		for _, op := range clause.ops {
+			perm := op.perm
+			if op.condX && (dir || cur&0111 != 0) {
+				perm |= 0111
+			}
+			if op.copy != 0 {
+				var bits uint32
+				switch op.copy {
+				case 'u':
+					bits = (cur >> 6) & 7
+				case 'g':
+					bits = (cur >> 3) & 7
+				case 'o':
+					bits = cur & 7
+				}
+				perm = bits * 0111
+			}
+
+			value := perm & who &^ protected
+			switch op.op {
+			case '+':
+				cur |= value
+			case '-':
+				cur &^= value
+			case '=':
+				// umask only restricts what is set, like GNU chmod
+				clear := who
+				if dir {
+					clear &^= (modeSetuid | modeSetgid) &^ perm
+				}
+				cur = cur&^clear | value
+			}
+		}
+	}
+	return fromUnixMode(mode, cur)
+}
+
+// toUnixMode the 07777 bits of mode
+func toUnixMode(mode os.FileMode) uint32 {
+	v := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		v |= modeSetuid
+	}
+	if mode&os.ModeSetgid != 0 {
+		v |= modeSetgid
+	}
+	if mode&os.ModeSticky != 0 {
+		v |= modeSticky
+	}
+	return v
+}
+
+// fromUnixMode replace the permission, setuid, setgid and sticky bits of mode with v
+func fromUnixMode(mode os.FileMode, v uint32) os.FileMode {
+	mode &^= os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+	mode |= os.FileMode(v & 0777)
+	if v&modeSetuid != 0 {
+		mode |= os.ModeSetuid
+	}
+	if v&modeSetgid != 0 {
+		mode |= os.ModeSetgid
+	}
+	if v&modeSticky != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
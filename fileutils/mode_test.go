@@ -0,0 +1,47 @@
+package fileutils
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		spec    string
+		mode    os.FileMode
+		umask   os.FileMode
+		correct os.FileMode
+	}{
+		{"0755", 0600, 022, 0755},
+		{"4755", 0600, 022, os.ModeSetuid | 0755},
+		{"u+rwX,go-w", 0666, 022, 0644},
+		{"u+rwX,go-w", os.ModeDir | 0666, 022, os.ModeDir | 0744},
+		{"a+X", 0744, 022, 0755},
+		{"+w", 0444, 022, 0644},
+		{"=r", 0777, 022, 0444},
+		{"g=u", 0750, 022, 0770},
+		{"o=g-x", 0751, 022, 0754},
+		{"u+s,+t", 0755, 022, os.ModeSetuid | os.ModeSticky | 0755},
+		{"o+t", 0755, 022, os.ModeSticky | 0755},
+		{"g-s", os.ModeSetgid | 0755, 022, 0755},
+		{"0755", os.ModeDir | os.ModeSetgid | 0700, 022, os.ModeDir | os.ModeSetgid | 0755},
+		{"00755", os.ModeDir | os.ModeSetgid | 0700, 022, os.ModeDir | 0755},
+	}
+
+	for _, v := range tests {
+		spec, err := ParseMode(v.spec)
+		if err != nil {
+			t.Errorf("[fileutils]ParseMode %s test failed, expecting nil, got %v", v.spec, err)
+			continue
+		}
+		if m := spec.Apply(v.mode, v.umask); m != v.correct {
+			t.Errorf("[fileutils]ParseMode %s on %v test failed, expecting %v, got %v", v.spec, v.mode, v.correct, m)
+		}
+	}
+
+	for _, v := range []string{"", "8", "u", "u+q", "77777", "z+r"} {
+		if _, err := ParseMode(v); err == nil {
+			t.Errorf("[fileutils]ParseMode %q test failed, expecting error, got nil", v)
+		}
+	}
+}
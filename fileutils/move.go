@@ -0,0 +1,223 @@
+package fileutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/marguerite/go-stdlib/extglob"
+	"github.com/marguerite/go-stdlib/internal"
+)
+
+// rename is replaced in tests to simulate moves across filesystems
+var rename = os.Rename
+
+// MoveOptions options for Move
+type MoveOptions struct {
+	// NoClobber never overwrite an existing destination, like `mv -n`
+	NoClobber bool
+	// Update only overwrite a destination older than the source, like `mv -u`
+	Update bool
+	// Backup back up an existing destination before overwriting it
+	Backup BackupMode
+	// Suffix the suffix of simple backups, "~" if empty
+	Suffix string
+	// Progress receive the progress of the move, the sources are scanned ahead for the totals
+	Progress ProgressFunc
+	// ProgressInterval the minimum time between two progress reports, 100ms if zero
+	ProgressInterval time.Duration
+}
+
+// Move like Linux's mv command, move files/directories matching the extglob
+// pattern src to dest. if dest is an existing directory, sources are moved into it.
+// a rename is tried first, which is atomic on the same filesystem. across
+// filesystems the source is copied with its attributes, verified and removed.
+// a backup of dest taken by a move that fails is put back.
+func Move(src, dest string, opts MoveOptions) error {
+	return MoveContext(context.Background(), src, dest, opts)
+}
+
+// MoveContext Move that can be cancelled through ctx. a source being copied
+// across filesystems when ctx is done is left in place, and its partial copy removed.
+func MoveContext(ctx context.Context, src, dest string, opts MoveOptions) error {
+	sources, err := extglob.Expand(internal.Str2bytes(src))
+	if err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		return &os.PathError{Op: "move", Path: src, Err: os.ErrNotExist}
+	}
+	if len(sources) > 1 {
+		if di, err := os.Stat(dest); err != nil || !di.IsDir() {
+			return fmt.Errorf("target %s is not a directory", dest)
+		}
+	}
+
+	c := newCopier(CopyOptions{Archive: true})
+	c.ctx = ctx
+	c.prog = newProgress(opts.Progress, opts.ProgressInterval)
+	defer c.prog.flush()
+	files := make([]int, len(sources))
+	bytes := make([]int64, len(sources))
+	if c.prog != nil {
+		for i, v := range sources {
+			files[i], bytes[i], err = scanTree(v, false)
+			if err != nil {
+				return err
+			}
+			c.prog.total(files[i], bytes[i])
+		}
+	}
+
+	for i, v := range sources {
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+		c.prog.start(v)
+		copied, err := move(c, v, dest, opts)
+		if err != nil {
+			return err
+		}
+		if !copied {
+			c.prog.done(files[i], bytes[i])
+		}
+	}
+	return nil
+}
+
+// move move source to dest. copied tells whether it was copied across
+// filesystems by c, which accounts the progress of the copy itself.
+func move(c *copier, source, dest string, opts MoveOptions) (copied bool, err error) {
+	si, err := os.Lstat(source)
+	if err != nil {
+		return false, err
+	}
+
+	target := dest
+	if di, err := os.Stat(dest); err == nil && di.IsDir() {
+		target = filepath.Join(dest, filepath.Base(source))
+	}
+
+	if si.IsDir() && isWithin(source, target) {
+		return false, fmt.Errorf("cannot move a directory %s into itself %s", source, target)
+	}
+
+	var bak string
+	defer func() {
+		// put the backup back if target was not replaced, like mv
+		if err != nil && len(bak) > 0 {
+			if _, err1 := os.Lstat(target); os.IsNotExist(err1) {
+				os.Rename(bak, target)
+			}
+		}
+	}()
+
+	ti, err := os.Lstat(target)
+	switch {
+	case err == nil:
+		if os.SameFile(si, ti) {
+			return false, fmt.Errorf("%s and %s are the same file", source, target)
+		}
+		if opts.NoClobber {
+			return false, nil
+		}
+		if opts.Update && !ti.ModTime().Before(si.ModTime()) {
+			return false, nil
+		}
+		if ti.IsDir() && !si.IsDir() {
+			return false, fmt.Errorf("cannot overwrite directory %s with non-directory %s", target, source)
+		}
+		if !ti.IsDir() && si.IsDir() {
+			return false, fmt.Errorf("cannot overwrite non-directory %s with directory %s", target, source)
+		}
+		bak, err = backup(target, opts.Backup, opts.Suffix)
+		if err != nil {
+			return false, err
+		}
+	case !os.IsNotExist(err):
+		return false, err
+	}
+
+	err = rename(source, target)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return false, err
+	}
+	err = moveAcross(c, source, target, si)
+	return true, err
+}
+
+// moveAcross move source to target on another filesystem: copy to a temporary
+// name next to target, verify, rename into place and remove the source
+func moveAcross(c *copier, source, target string, si os.FileInfo) error {
+	tmp, err := ioutil.TempDir(filepath.Dir(target), "."+filepath.Base(target)+".move")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	staged := filepath.Join(tmp, filepath.Base(target))
+	err = c.walk(source, staged, si)
+	if err == nil {
+		err = c.finish()
+	}
+	if err != nil {
+		return err
+	}
+
+	err = verifyTree(source, staged)
+	if err != nil {
+		return err
+	}
+
+	// rename(2) replaces an empty directory, and fails on another one, like mv.
+	// os.Rename refuses any directory in the way.
+	err = syscall.Rename(staged, target)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: staged, New: target, Err: err}
+	}
+	return os.RemoveAll(source)
+}
+
+// verifyTree make sure every regular file and symlink under source has an identical copy under target
+func verifyTree(source, target string) error {
+	return filepath.Walk(source, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(source, p)
+		if err != nil {
+			return err
+		}
+		t := filepath.Join(target, rel)
+
+		switch mode := fi.Mode(); {
+		case mode.IsRegular():
+			same, err := sameContent(p, t)
+			if err != nil {
+				return err
+			}
+			if !same {
+				return fmt.Errorf("verifying %s failed: content of %s differs", p, t)
+			}
+		case mode&os.ModeSymlink != 0:
+			l1, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			l2, err := os.Readlink(t)
+			if err != nil {
+				return err
+			}
+			if l1 != l2 {
+				return fmt.Errorf("verifying %s failed: %s points to %s instead of %s", p, t, l2, l1)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,142 @@
+package fileutils
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/marguerite/go-stdlib/dir"
+)
+
+func TestMove(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "a.conf", Content: "new"},
+		{Path: "b.conf", Content: "new"},
+		{Path: "dst/a.conf", Content: "old"},
+		{Path: "dst/b.conf", Content: "old"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	err = Move(tree.Path("a.conf"), tree.Path("dst"), MoveOptions{NoClobber: true})
+	if b, _ := ioutil.ReadFile(tree.Path("dst/a.conf")); err != nil || string(b) != "old" {
+		t.Errorf("[fileutils]Move NoClobber test failed, expecting old, got %s, err %v", b, err)
+	}
+
+	err = Move(tree.Path("*.conf"), tree.Path("dst"), MoveOptions{Backup: NumberedBackup})
+	if err != nil {
+		t.Fatalf("[fileutils]Move test failed, expecting nil, got %v", err)
+	}
+	for p, correct := range map[string]string{"dst/a.conf": "new", "dst/a.conf.~1~": "old", "dst/b.conf.~1~": "old"} {
+		if b, err := ioutil.ReadFile(tree.Path(p)); err != nil || string(b) != correct {
+			t.Errorf("[fileutils]Move backup test failed, expecting %s to be %s, got %s, err %v", p, correct, b, err)
+		}
+	}
+	if _, err := os.Stat(tree.Path("a.conf")); !os.IsNotExist(err) {
+		t.Error("[fileutils]Move test failed, source still exists")
+	}
+}
+
+func TestMoveUpdate(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "src", Content: "older"},
+		{Path: "dst", Content: "newer"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(tree.Path("src"), old, old)
+
+	err = Move(tree.Path("src"), tree.Path("dst"), MoveOptions{Update: true})
+	if b, _ := ioutil.ReadFile(tree.Path("dst")); err != nil || string(b) != "newer" {
+		t.Errorf("[fileutils]Move Update test failed, expecting newer, got %s, err %v", b, err)
+	}
+}
+
+func TestMoveAcrossFilesystems(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "src/bin/run", Mode: 0755, Content: "#!/bin/sh\n"},
+		{Path: "src/empty", Mode: os.ModeDir},
+		{Path: "src/link", Mode: os.ModeSymlink, Target: "bin/run"},
+		{Path: "dst/src", Mode: os.ModeDir},
+		{Path: "full/src/keep", Content: "keep"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	rename = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+	defer func() { rename = os.Rename }()
+
+	before, _ := (&dir.Tree{Root: tree.Path("src")}).Snapshot()
+	// a directory in the way is only replaced if empty
+	err = Move(tree.Path("src"), tree.Path("full"), MoveOptions{})
+	if err == nil {
+		t.Error("[fileutils]Move across filesystems test failed, expecting an error for a non-empty directory, got nil")
+	}
+	if b, err := ioutil.ReadFile(tree.Path("full/src/keep")); err != nil || string(b) != "keep" {
+		t.Errorf("[fileutils]Move across filesystems test failed, expecting the target kept, got %q, err %v", b, err)
+	}
+	if items, _ := ioutil.ReadDir(tree.Path("full")); len(items) != 1 {
+		t.Errorf("[fileutils]Move across filesystems test failed, expecting no temporary left, got %d items", len(items))
+	}
+
+	err = Move(tree.Path("src"), tree.Path("dst"), MoveOptions{})
+	if err != nil {
+		t.Fatalf("[fileutils]Move across filesystems test failed, expecting nil, got %v", err)
+	}
+
+	after, err := (&dir.Tree{Root: tree.Path("dst/src")}).Snapshot()
+	if err != nil || !reflect.DeepEqual(before, after) {
+		t.Errorf("[fileutils]Move across filesystems test failed, expecting %v, got %v, err %v", before, after, err)
+	}
+	if _, err := os.Stat(tree.Path("src")); !os.IsNotExist(err) {
+		t.Error("[fileutils]Move across filesystems test failed, source still exists")
+	}
+	if items, _ := ioutil.ReadDir(tree.Path("dst")); len(items) != 1 {
+		t.Errorf("[fileutils]Move across filesystems test failed, expecting no temporary left, got %d items", len(items))
+	}
+}
+
+func TestMoveRestoreBackup(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "src", Content: "new"},
+		{Path: "dst", Content: "old"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+	defer func() { rename = os.Rename }()
+
+	for _, e := range []syscall.Errno{syscall.EACCES, syscall.EXDEV} {
+		// cancelling makes the copy across filesystems fail too
+		ctx, cancel := context.WithCancel(context.Background())
+		rename = func(oldpath, newpath string) error {
+			cancel()
+			return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: e}
+		}
+		err = MoveContext(ctx, tree.Path("src"), tree.Path("dst"), MoveOptions{Backup: SimpleBackup})
+		if err == nil {
+			t.Errorf("[fileutils]Move restore backup test failed, expecting %v, got nil", e)
+		}
+		if b, err := ioutil.ReadFile(tree.Path("dst")); err != nil || string(b) != "old" {
+			t.Errorf("[fileutils]Move restore backup test failed, expecting old, got %s, err %v", b, err)
+		}
+		if _, err := os.Lstat(tree.Path("dst~")); !os.IsNotExist(err) {
+			t.Errorf("[fileutils]Move restore backup test failed, backup still exists, err %v", err)
+		}
+	}
+}
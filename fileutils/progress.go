@@ -0,0 +1,160 @@
+package fileutils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/marguerite/go-stdlib/internal"
+)
+
+// defaultProgressInterval the minimum time between two progress reports when none is given
+const defaultProgressInterval = 100 * time.Millisecond
+
+// Progress the progress of a long running operation like CopyContext
+type Progress struct {
+	FilesDone  int
+	FilesTotal int
+	BytesDone  int64
+	BytesTotal int64
+	// Path the source being worked on
+	Path string
+}
+
+// ProgressFunc receive progress reports. it is never called concurrently,
+// and it is called a last time when the operation ends.
+type ProgressFunc func(Progress)
+
+// progress the throttled progress of an operation, all its methods do nothing on nil
+type progress struct {
+	mu       sync.Mutex
+	fn       ProgressFunc
+	interval time.Duration
+	last     time.Time
+	state    Progress
+}
+
+func newProgress(fn ProgressFunc, interval time.Duration) *progress {
+	if fn == nil {
+		return nil
+	}
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+	return &progress{fn: fn, interval: interval}
+}
+
+// total add files and bytes to the totals
+func (p *progress) total(files int, bytes int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.state.FilesTotal += files
+	p.state.BytesTotal += bytes
+	p.mu.Unlock()
+}
+
+// start report that path is being worked on
+func (p *progress) start(path string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.state.Path = path
+	p.report(false)
+	p.mu.Unlock()
+}
+
+// bytes account n more bytes done
+func (p *progress) bytes(n int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.state.BytesDone += n
+	p.report(false)
+	p.mu.Unlock()
+}
+
+// done account files more files done along with their bytes not accounted yet
+func (p *progress) done(files int, bytes int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.state.FilesDone += files
+	p.state.BytesDone += bytes
+	p.report(false)
+	p.mu.Unlock()
+}
+
+// flush report the final state
+func (p *progress) flush() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.report(true)
+	p.mu.Unlock()
+}
+
+// report call fn unless the last call was too recent, p.mu is held
+func (p *progress) report(force bool) {
+	now := time.Now()
+	if !force && now.Sub(p.last) < p.interval {
+		return
+	}
+	p.last = now
+	p.fn(p.state)
+}
+
+// scanTree count the non-directory entries under path, path itself included,
+// and the size of the regular files among them. symlinks are followed if follow,
+// symlinks leading back to a directory being scanned are not.
+func scanTree(path string, follow bool) (files int, bytes int64, err error) {
+	fi, err := statLink(path, !follow)
+	if err != nil {
+		return 0, 0, err
+	}
+	return scan(path, fi, follow, make(map[internal.FileID]bool))
+}
+
+func scan(path string, fi os.FileInfo, follow bool, ancestors map[internal.FileID]bool) (files int, bytes int64, err error) {
+	if !fi.IsDir() {
+		if fi.Mode().IsRegular() {
+			return 1, fi.Size(), nil
+		}
+		return 1, 0, nil
+	}
+
+	if id, _, ok := internal.StatID(fi); ok {
+		if ancestors[id] {
+			return 0, 0, nil
+		}
+		ancestors[id] = true
+		defer delete(ancestors, id)
+	}
+
+	items, err := ioutil.ReadDir(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, item := range items {
+		p := filepath.Join(path, item.Name())
+		if follow && item.Mode()&os.ModeSymlink != 0 {
+			if fi, err := os.Stat(p); err == nil {
+				item = fi
+			}
+		}
+		f, b, err := scan(p, item, follow, ancestors)
+		if err != nil {
+			return files, bytes, err
+		}
+		files += f
+		bytes += b
+	}
+	return files, bytes, nil
+}
@@ -0,0 +1,95 @@
+package fileutils
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/marguerite/go-stdlib/dir"
+)
+
+func TestCopyContextProgress(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "src/a", Content: "aaaa"},
+		{Path: "src/sub/b", Content: "bb"},
+		{Path: "src/sub/link", Mode: os.ModeSymlink, Target: "b"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	var reports []Progress
+	opts := CopyOptions{NoDereference: true, Progress: func(p Progress) { reports = append(reports, p) }}
+	err = CopyContext(context.Background(), tree.Path("src"), tree.Path("dst"), opts)
+	if err != nil {
+		t.Fatalf("[fileutils]CopyContext test failed, expecting nil, got %v", err)
+	}
+
+	last := reports[len(reports)-1]
+	correct := Progress{FilesDone: 3, FilesTotal: 3, BytesDone: 6, BytesTotal: 6, Path: tree.Path("src/sub/link")}
+	if last != correct {
+		t.Errorf("[fileutils]CopyContext test failed, expecting last report %v, got %v", correct, last)
+	}
+}
+
+func TestCopyContextCancel(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "src/a", Content: "a"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	// big enough to be copied in several chunks
+	b := make([]byte, 24<<20)
+	rand.Read(b)
+	err = ioutil.WriteFile(tree.Path("src/big"), b, 0644)
+	if err != nil {
+		t.Fatalf("[fileutils]WriteFile failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts := CopyOptions{Reflink: ReflinkNever, ProgressInterval: 1, Progress: func(p Progress) {
+		if p.BytesDone > 1 {
+			cancel()
+		}
+	}}
+	err = CopyContext(ctx, tree.Path("src"), tree.Path("dst"), opts)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("[fileutils]CopyContext test failed, expecting %v, got %v", context.Canceled, err)
+	}
+
+	_, err = os.Lstat(tree.Path("dst/big"))
+	if !os.IsNotExist(err) {
+		t.Errorf("[fileutils]CopyContext test failed, expecting the partial copy removed, got %v", err)
+	}
+	if b, err := ioutil.ReadFile(tree.Path("dst/a")); err != nil || string(b) != "a" {
+		t.Errorf("[fileutils]CopyContext test failed, expecting the finished copy kept, got %q, err %v", b, err)
+	}
+}
+
+func TestSyncContextCancel(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "src/a", Content: "a"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = SyncContext(ctx, tree.Path("src"), tree.Path("dst"), SyncOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("[fileutils]SyncContext test failed, expecting %v, got %v", context.Canceled, err)
+	}
+	if _, err := os.Lstat(tree.Path("dst/a")); !os.IsNotExist(err) {
+		t.Errorf("[fileutils]SyncContext test failed, expecting nothing transferred, got %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+package fileutils
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/marguerite/go-stdlib/extglob"
+	"github.com/marguerite/go-stdlib/internal"
+)
+
+var (
+	ErrPreserveRoot = errors.New("refusing to remove the root directory or the home directory")
+)
+
+// RemoveOptions options for Remove
+type RemoveOptions struct {
+	// Recursive remove directories and their contents, like `rm -r`
+	Recursive bool
+	// Force ignore a pattern matching nothing, like `rm -f`
+	Force bool
+	// AllowRoot allow removing "/", like `rm --no-preserve-root`
+	AllowRoot bool
+	// AllowHome allow removing the user's home directory or a directory containing it
+	AllowHome bool
+	// DryRun only return the paths that would be removed
+	DryRun bool
+	// OneFileSystem skip directories on a filesystem other than their argument's, like `rm --one-file-system`
+	OneFileSystem bool
+	// SecureErase overwrite regular files before removing them, like `shred -u`.
+	// files with other hard links are not overwritten, as that would destroy the other links.
+	SecureErase bool
+	// ErasePasses the number of random overwrites of SecureErase, 3 if zero
+	ErasePasses int
+}
+
+// Remove like Linux's rm command, remove files matching the extglob pattern.
+// symlinks are removed, never followed. directories unreadable or unwritable
+// by the owner are made so while being emptied, and given their mode back if
+// they are kept after all. it returns the removed paths in the
+// order of removal, or the paths to remove in DryRun.
+func Remove(pattern string, opts RemoveOptions) ([]string, error) {
+	var removed []string
+
+	// extglob expands "/" to nothing, check the plain path itself too
+	if !extglob.IsPattern(internal.Str2bytes(pattern), true) {
+		err := guard(pattern, opts)
+		if err != nil {
+			return removed, err
+		}
+	}
+
+	paths, err := extglob.Expand(internal.Str2bytes(pattern))
+	if err != nil {
+		return removed, err
+	}
+	if len(paths) == 0 && !opts.Force {
+		return removed, &os.PathError{Op: "remove", Path: pattern, Err: os.ErrNotExist}
+	}
+
+	for _, p := range paths {
+		err := guard(p, opts)
+		if err != nil {
+			return removed, err
+		}
+	}
+
+	var skipped []string
+	for _, p := range paths {
+		fi, err := os.Lstat(p)
+		if err != nil {
+			if os.IsNotExist(err) && opts.Force {
+				continue
+			}
+			return removed, err
+		}
+		if fi.IsDir() && !opts.Recursive {
+			return removed, fmt.Errorf("cannot remove %s: is a directory", p)
+		}
+
+		dev, _, _ := internal.StatID(fi)
+		r, skip, err := removeTree(p, fi, dev.Dev, opts)
+		removed = append(removed, r...)
+		skipped = append(skipped, skip...)
+		if err != nil {
+			return removed, err
+		}
+	}
+
+	if len(skipped) > 0 {
+		return removed, fmt.Errorf("skipped %v: on a different filesystem", skipped)
+	}
+	return removed, nil
+}
+
+// RemoveTree like `rm -rf`, Remove with Recursive and Force
+func RemoveTree(pattern string, opts RemoveOptions) ([]string, error) {
+	opts.Recursive = true
+	opts.Force = true
+	return Remove(pattern, opts)
+}
+
+// guard refuse to remove "/" and the home directory unless allowed
+func guard(path string, opts RemoveOptions) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return nil
+	}
+
+	if !opts.AllowRoot {
+		if ri, err := os.Stat("/"); err == nil && os.SameFile(fi, ri) {
+			return fmt.Errorf("%w: %s", ErrPreserveRoot, path)
+		}
+	}
+
+	if !opts.AllowHome {
+		home, err := os.UserHomeDir()
+		if err != nil || len(home) == 0 {
+			return nil
+		}
+		if hi, err := os.Stat(home); err == nil && os.SameFile(fi, hi) {
+			return fmt.Errorf("%w: %s", ErrPreserveRoot, path)
+		}
+		if fi.IsDir() && isWithin(path, home) {
+			return fmt.Errorf("%w: %s contains %s", ErrPreserveRoot, path, home)
+		}
+	}
+	return nil
+}
+
+// removeTree remove path and everything under it, children before their parents.
+// directories on another device than dev are skipped in OneFileSystem mode,
+// and so are their ancestors, which can not be emptied.
+func removeTree(path string, fi os.FileInfo, dev uint64, opts RemoveOptions) (removed, skipped []string, err error) {
+	if !fi.IsDir() {
+		if !opts.DryRun {
+			err = removeOne(path, opts)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		return []string{path}, nil, nil
+	}
+
+	if opts.OneFileSystem {
+		if id, _, ok := internal.StatID(fi); ok && id.Dev != dev {
+			return nil, []string{path}, nil
+		}
+	}
+
+	if !opts.DryRun && fi.Mode().Perm()&0700 != 0700 {
+		// a read-only directory can not be emptied, its mode is put back if it is kept
+		err = os.Chmod(path, fi.Mode().Perm()|0700)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer func() {
+			if err != nil || len(skipped) > 0 {
+				os.Chmod(path, fi.Mode()&(os.ModePerm|os.ModeSetgid|os.ModeSticky))
+			}
+		}()
+	}
+
+	items, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, item := range items {
+		r, s, err := removeTree(filepath.Join(path, item.Name()), item, dev, opts)
+		removed = append(removed, r...)
+		skipped = append(skipped, s...)
+		if err != nil {
+			return removed, skipped, err
+		}
+	}
+
+	if len(skipped) == 0 {
+		if !opts.DryRun {
+			err = removeOne(path, opts)
+			if err != nil {
+				return removed, skipped, err
+			}
+		}
+		removed = append(removed, path)
+	}
+	return removed, skipped, nil
+}
+
+func removeOne(path string, opts RemoveOptions) error {
+	if opts.SecureErase {
+		fi, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+		if _, nlink, ok := internal.StatID(fi); fi.Mode().IsRegular() && (!ok || nlink <= 1) {
+			err = erase(path, fi.Size(), opts.ErasePasses)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return os.Remove(path)
+}
+
+// erase overwrite the file with random data passes times, syncing after each pass, then truncate it
+func erase(path string, size int64, passes int) error {
+	if passes <= 0 {
+		passes = 3
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil && os.IsPermission(err) {
+		// the file itself may be read-only
+		err = os.Chmod(path, 0600)
+		if err == nil {
+			f, err = os.OpenFile(path, os.O_WRONLY, 0)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := make([]byte, 64*1024)
+	for i := 0; i < passes; i++ {
+		_, err = f.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		_, err = io.CopyBuffer(struct{ io.Writer }{f}, io.LimitReader(rand.Reader, size), buf)
+		if err != nil {
+			return err
+		}
+		err = f.Sync()
+		if err != nil {
+			return err
+		}
+	}
+	return f.Truncate(0)
+}
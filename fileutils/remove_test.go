@@ -0,0 +1,103 @@
+package fileutils
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/marguerite/go-stdlib/dir"
+)
+
+func TestRemove(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "build/a.o", Content: "a"},
+		{Path: "build/ro/b.o", Content: "b"},
+		{Path: "build/link", Mode: os.ModeSymlink, Target: "/"},
+		{Path: "build/ro", Mode: os.ModeDir | 0555},
+		{Path: "keep", Content: "k"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	correct := []string{tree.Path("build/a.o"), tree.Path("build/link"), tree.Path("build/ro/b.o"), tree.Path("build/ro"), tree.Path("build")}
+	plan, err := Remove(tree.Path("build"), RemoveOptions{Recursive: true, DryRun: true})
+	if err != nil || !reflect.DeepEqual(plan, correct) {
+		t.Errorf("[fileutils]Remove DryRun test failed, expecting %v, got %v, err %v", correct, plan, err)
+	}
+	if _, err := os.Stat(tree.Path("build/a.o")); err != nil {
+		t.Error("[fileutils]Remove DryRun test failed, build/a.o is removed")
+	}
+
+	if _, err := Remove(tree.Path("build"), RemoveOptions{}); err == nil {
+		t.Error("[fileutils]Remove test failed, expecting error removing a directory without Recursive, got nil")
+	}
+
+	removed, err := RemoveTree(tree.Path("build"), RemoveOptions{})
+	if err != nil || !reflect.DeepEqual(removed, correct) {
+		t.Errorf("[fileutils]RemoveTree test failed, expecting %v, got %v, err %v", correct, removed, err)
+	}
+	if _, err := os.Stat(tree.Path("keep")); err != nil {
+		t.Errorf("[fileutils]RemoveTree test failed, keep is removed: %v", err)
+	}
+
+	if _, err := Remove(tree.Path("missing*"), RemoveOptions{}); err == nil {
+		t.Error("[fileutils]Remove test failed, expecting error for a pattern matching nothing, got nil")
+	}
+	if _, err := Remove(tree.Path("missing*"), RemoveOptions{Force: true}); err != nil {
+		t.Errorf("[fileutils]Remove Force test failed, expecting nil, got %v", err)
+	}
+}
+
+func TestRemovePreserveRoot(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{{Path: "home/marguerite/.bashrc"}})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	if _, err := RemoveTree("/", RemoveOptions{DryRun: true}); !errors.Is(err, ErrPreserveRoot) {
+		t.Errorf("[fileutils]Remove test failed, expecting ErrPreserveRoot for /, got %v", err)
+	}
+
+	home := os.Getenv("HOME")
+	defer os.Setenv("HOME", home)
+	os.Setenv("HOME", tree.Path("home/marguerite"))
+
+	for _, p := range []string{"home/marguerite", "home"} {
+		if _, err := RemoveTree(tree.Path(p), RemoveOptions{DryRun: true}); !errors.Is(err, ErrPreserveRoot) {
+			t.Errorf("[fileutils]Remove test failed, expecting ErrPreserveRoot for %s, got %v", p, err)
+		}
+	}
+	if _, err := RemoveTree(tree.Path("home"), RemoveOptions{AllowHome: true}); err != nil {
+		t.Errorf("[fileutils]Remove AllowHome test failed, expecting nil, got %v", err)
+	}
+}
+
+func TestRemoveSecureErase(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "secret", Mode: 0400, Content: "password"},
+		{Path: "shared", Content: "shared"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	os.Link(tree.Path("shared"), tree.Path("shared.link"))
+
+	_, err = Remove(tree.Path("s{ecret,hared}"), RemoveOptions{SecureErase: true, ErasePasses: 1})
+	if err != nil {
+		t.Fatalf("[fileutils]Remove SecureErase test failed, expecting nil, got %v", err)
+	}
+	if _, err := os.Stat(tree.Path("secret")); !os.IsNotExist(err) {
+		t.Error("[fileutils]Remove SecureErase test failed, secret still exists")
+	}
+	// other hard links keep their content
+	if b, err := ioutil.ReadFile(tree.Path("shared.link")); err != nil || string(b) != "shared" {
+		t.Errorf("[fileutils]Remove SecureErase test failed, expecting shared.link untouched, got %s, err %v", b, err)
+	}
+}
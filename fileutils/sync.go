@@ -0,0 +1,421 @@
+package fileutils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/marguerite/go-stdlib/dir"
+	"github.com/marguerite/go-stdlib/internal"
+)
+
+// SyncOptions options for Sync
+type SyncOptions struct {
+	// Delete remove destination paths missing from the source, like `rsync --delete`
+	Delete bool
+	// Checksum compare the contents of files of the same size, like `rsync -c`.
+	// otherwise files of the same size and modification time are taken as unchanged.
+	Checksum bool
+	// DryRun only return the changes Sync would make, like `rsync -n`
+	DryRun bool
+	// Excludes extglob patterns of paths to leave alone, like `rsync --exclude`: one
+	// without a slash matches names at any depth, one with a slash paths relative to
+	// src and dst. excluded destination paths are not deleted either.
+	Excludes []string
+	// PreserveAttrs keep mode, owner and xattrs too, like `rsync -a`.
+	// a difference in mode or owner then updates a file.
+	PreserveAttrs bool
+	// Parallelism the number of files transferred at once, 1 if zero
+	Parallelism int
+	// Progress receive the progress of the transfers, the totals are the files to transfer
+	Progress ProgressFunc
+	// ProgressInterval the minimum time between two progress reports, 100ms if zero
+	ProgressInterval time.Duration
+}
+
+// SyncAction what Sync did to a destination path
+type SyncAction int
+
+const (
+	// SyncCreated the path was missing from the destination
+	SyncCreated SyncAction = iota
+	// SyncUpdated the path differed from the source
+	SyncUpdated
+	// SyncDeleted the path was missing from the source
+	SyncDeleted
+)
+
+func (a SyncAction) String() string {
+	switch a {
+	case SyncCreated:
+		return "created"
+	case SyncUpdated:
+		return "updated"
+	case SyncDeleted:
+		return "deleted"
+	}
+	return fmt.Sprintf("SyncAction(%d)", int(a))
+}
+
+// SyncChange a change Sync made, or would make in DryRun
+type SyncChange struct {
+	// Path the path relative to src and dst
+	Path   string
+	Action SyncAction
+}
+
+type syncer struct {
+	src  string
+	dst  string
+	opts SyncOptions
+	// c copies everything but regular files, and applies directory attributes
+	c       *copier
+	changes []SyncChange
+	files   []syncFile
+}
+
+type syncFile struct {
+	source string
+	target string
+	fi     os.FileInfo
+}
+
+// Sync like `rsync -r --links --times src/ dst/`, make the directory dst a mirror of
+// the directory src, transferring only what changed. symlinks are kept as symlinks and
+// empty directories are kept. modification times are always kept, so unchanged files
+// are told by size and time. files are replaced atomically. it returns the changes in
+// the order they were made, directories before their contents and deletions last.
+func Sync(src, dst string, opts SyncOptions) ([]SyncChange, error) {
+	return SyncContext(context.Background(), src, dst, opts)
+}
+
+// SyncContext Sync that can be cancelled through ctx. files being transferred
+// when ctx is done are left untouched, the changes made so far are returned.
+func SyncContext(ctx context.Context, src, dst string, opts SyncOptions) ([]SyncChange, error) {
+	si, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+	if !si.IsDir() {
+		return nil, fmt.Errorf("source %s is not a directory", src)
+	}
+
+	copts := CopyOptions{NoDereference: true, PreserveTimestamps: true}
+	if opts.PreserveAttrs {
+		copts.Archive = true
+	}
+	s := &syncer{src: src, dst: dst, opts: opts, c: newCopier(copts)}
+	s.c.ctx = ctx
+	s.c.prog = newProgress(opts.Progress, opts.ProgressInterval)
+	defer s.c.prog.flush()
+	// hard links are not tracked, files are transferred concurrently
+	s.c.opts.PreserveLinks = false
+
+	if !opts.DryRun {
+		_, err = dir.MkdirPWithOptions(dst, dir.MkdirOptions{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = filepath.Walk(src, s.visit)
+	if err == nil {
+		err = s.transfer()
+	}
+	if err == nil && opts.Delete {
+		err = s.delete()
+	}
+	if err == nil && !opts.DryRun {
+		err = s.c.finish()
+	}
+	return s.changes, err
+}
+
+func (s *syncer) record(path string, action SyncAction) {
+	s.changes = append(s.changes, SyncChange{path, action})
+}
+
+func (s *syncer) visit(path string, fi os.FileInfo, err error) error {
+	if err != nil {
+		return err
+	}
+	err = s.c.ctx.Err()
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(s.src, path)
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		if !s.opts.DryRun {
+			s.c.dirs = append(s.c.dirs, dirAttr{path, s.dst, fi, false})
+		}
+		return nil
+	}
+	excluded, err := matchPath(s.opts.Excludes, rel, fi.IsDir())
+	if err != nil {
+		return err
+	}
+	if excluded {
+		if fi.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	}
+
+	target := filepath.Join(s.dst, rel)
+	ti, err := os.Lstat(target)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	action := SyncCreated
+
+	if exists && ti.Mode()&os.ModeType != fi.Mode()&os.ModeType {
+		// a file became a directory or the like, start over
+		action = SyncUpdated
+		exists = false
+		if !s.opts.DryRun {
+			err = os.RemoveAll(target)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	switch mode := fi.Mode(); {
+	case mode.IsDir():
+		if !exists {
+			s.record(rel, action)
+		}
+		if s.opts.DryRun {
+			return nil
+		}
+		if !exists {
+			// owner write permission is needed to populate the directory, it is fixed up later
+			err = os.Mkdir(target, fi.Mode().Perm()|0700)
+			if err != nil {
+				return err
+			}
+		}
+		s.c.dirs = append(s.c.dirs, dirAttr{path, target, fi, !exists})
+		return nil
+	case mode.IsRegular():
+		if exists {
+			changed, err := s.changed(path, target, fi, ti)
+			if err != nil || !changed {
+				return err
+			}
+			action = SyncUpdated
+		}
+		s.record(rel, action)
+		if !s.opts.DryRun {
+			s.files = append(s.files, syncFile{path, target, fi})
+		}
+		return nil
+	case mode&os.ModeSymlink != 0:
+		link, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		if exists {
+			old, err := os.Readlink(target)
+			if err != nil {
+				return err
+			}
+			if old == link {
+				return nil
+			}
+			action = SyncUpdated
+		}
+		s.record(rel, action)
+		if s.opts.DryRun {
+			return nil
+		}
+		err = replaceWithLink(link, target, true)
+		if err != nil {
+			return err
+		}
+		return s.c.attrs(path, target, fi)
+	default:
+		if exists {
+			return nil
+		}
+		s.record(rel, action)
+		if s.opts.DryRun {
+			return nil
+		}
+		return s.c.special(path, target, fi)
+	}
+}
+
+// changed whether the regular file target needs to be transferred from source
+func (s *syncer) changed(source, target string, fi, ti os.FileInfo) (bool, error) {
+	if fi.Size() != ti.Size() {
+		return true, nil
+	}
+
+	if s.opts.PreserveAttrs {
+		if fi.Mode() != ti.Mode() {
+			return true, nil
+		}
+		uid1, gid1, _ := internal.StatOwner(fi)
+		uid2, gid2, _ := internal.StatOwner(ti)
+		if uid1 != uid2 || gid1 != gid2 {
+			return true, nil
+		}
+	}
+
+	if s.opts.Checksum {
+		same, err := sameContent(source, target)
+		return !same, err
+	}
+	return !fi.ModTime().Equal(ti.ModTime()), nil
+}
+
+// transfer copy the queued files with Parallelism workers, each with its own buffer
+func (s *syncer) transfer() error {
+	n := s.opts.Parallelism
+	if n <= 0 {
+		n = 1
+	}
+
+	for _, f := range s.files {
+		s.c.prog.total(1, f.fi.Size())
+	}
+
+	var mu sync.Mutex
+	var firstErr error
+	files := make(chan syncFile)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := newCopier(s.c.opts)
+			c.ctx = s.c.ctx
+			c.prog = s.c.prog
+			for f := range files {
+				mu.Lock()
+				failed := firstErr != nil
+				mu.Unlock()
+				if failed {
+					continue
+				}
+				err := c.ctx.Err()
+				if err == nil {
+					c.prog.start(f.source)
+					c.counted = 0
+					err = c.replace(f.source, f.target, f.fi)
+				}
+				if err == nil {
+					c.done(f.fi)
+				} else {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, f := range s.files {
+		files <- f
+	}
+	close(files)
+	wg.Wait()
+	return firstErr
+}
+
+// replace atomically replace target with a copy of the regular file source
+func (c *copier) replace(source, target string, fi os.FileInfo) (err error) {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := createAtomic(target)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.abort()
+		}
+	}()
+
+	err = c.transfer(tmp.File, in, fi)
+	if err != nil {
+		return err
+	}
+	if !c.opts.PreserveMode {
+		// the permission a new file would get, the temporary file is private
+		err = tmp.Chmod(fi.Mode().Perm() &^ internal.Umask())
+		if err != nil {
+			return err
+		}
+	}
+	err = tmp.close()
+	if err != nil {
+		return err
+	}
+	err = c.attrs(source, tmp.Name(), fi)
+	if err != nil {
+		return err
+	}
+	return tmp.commit(NoBackup, "")
+}
+
+// delete remove the destination paths missing from the source
+func (s *syncer) delete() error {
+	if _, err := os.Stat(s.dst); err != nil && s.opts.DryRun {
+		// nothing to delete from a destination yet to be created
+		return nil
+	}
+
+	return filepath.Walk(s.dst, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.dst, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		excluded, err := matchPath(s.opts.Excludes, rel, fi.IsDir())
+		if err != nil {
+			return err
+		}
+		if excluded {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		_, err = os.Lstat(filepath.Join(s.src, rel))
+		if err == nil {
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+
+		s.record(rel, SyncDeleted)
+		if !s.opts.DryRun {
+			err = os.RemoveAll(path)
+			if err != nil {
+				return err
+			}
+		}
+		if fi.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
@@ -0,0 +1,110 @@
+package fileutils
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/marguerite/go-stdlib/dir"
+)
+
+func TestSync(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "src/bin/app", Mode: 0755, Content: "app"},
+		{Path: "src/empty", Mode: os.ModeDir | 0755},
+		{Path: "src/lib/libapp.so.1", Mode: 0644, Content: "lib"},
+		{Path: "src/lib/libapp.so", Mode: os.ModeSymlink, Target: "libapp.so.1"},
+		{Path: "src/app.o", Mode: 0644, Content: "object"},
+		{Path: "src/sub/b.o", Mode: 0644, Content: "object"},
+		{Path: "dst/bin/app", Mode: 0755, Content: "old"},
+		{Path: "dst/stale", Mode: 0644, Content: "stale"},
+		{Path: "dst/keep.o", Mode: 0644, Content: "kept"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	opts := SyncOptions{Delete: true, Excludes: []string{"*.o"}, Parallelism: 2}
+
+	dry := opts
+	dry.DryRun = true
+	changes, err := Sync(tree.Path("src"), tree.Path("dst"), dry)
+	correct := []SyncChange{
+		{"bin/app", SyncUpdated},
+		{"empty", SyncCreated},
+		{"lib", SyncCreated},
+		{"lib/libapp.so", SyncCreated},
+		{"lib/libapp.so.1", SyncCreated},
+		{"sub", SyncCreated},
+		{"stale", SyncDeleted},
+	}
+	if err != nil || !reflect.DeepEqual(changes, correct) {
+		t.Errorf("[fileutils]Sync test failed, expecting %v, got %v, err %v", correct, changes, err)
+	}
+	if b, _ := ioutil.ReadFile(tree.Path("dst/bin/app")); string(b) != "old" {
+		t.Errorf("[fileutils]Sync test failed, expecting DryRun to change nothing, got %q", b)
+	}
+
+	changes, err = Sync(tree.Path("src"), tree.Path("dst"), opts)
+	if err != nil || !reflect.DeepEqual(changes, correct) {
+		t.Errorf("[fileutils]Sync test failed, expecting %v, got %v, err %v", correct, changes, err)
+	}
+
+	spec, err := (&dir.Tree{Root: tree.Path("dst")}).Snapshot()
+	mirror := dir.TreeSpec{
+		{Path: "bin", Mode: os.ModeDir | 0755},
+		{Path: "bin/app", Mode: 0755, Content: "app"},
+		{Path: "empty", Mode: os.ModeDir | 0755},
+		{Path: "keep.o", Mode: 0644, Content: "kept"},
+		{Path: "lib", Mode: os.ModeDir | 0755},
+		{Path: "lib/libapp.so", Mode: os.ModeSymlink, Target: "libapp.so.1"},
+		{Path: "lib/libapp.so.1", Mode: 0644, Content: "lib"},
+		{Path: "sub", Mode: os.ModeDir | 0755},
+	}
+	if err != nil || !reflect.DeepEqual(spec, mirror) {
+		t.Errorf("[fileutils]Sync test failed, expecting %v, got %v, err %v", mirror, spec, err)
+	}
+
+	// a second run has nothing to do
+	changes, err = Sync(tree.Path("src"), tree.Path("dst"), opts)
+	if err != nil || len(changes) != 0 {
+		t.Errorf("[fileutils]Sync test failed, expecting no changes, got %v, err %v", changes, err)
+	}
+}
+
+func TestSyncChecksum(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "src/a", Mode: 0644, Content: "new"},
+		{Path: "dst/a", Mode: 0644, Content: "old"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	// same size and time, only the content tells them apart
+	now := time.Now()
+	for _, v := range []string{"src/a", "dst/a"} {
+		err = os.Chtimes(tree.Path(v), now, now)
+		if err != nil {
+			t.Fatalf("[fileutils]Chtimes failed: %v", err)
+		}
+	}
+
+	changes, err := Sync(tree.Path("src"), tree.Path("dst"), SyncOptions{})
+	if err != nil || len(changes) != 0 {
+		t.Errorf("[fileutils]Sync test failed, expecting no changes, got %v, err %v", changes, err)
+	}
+
+	changes, err = Sync(tree.Path("src"), tree.Path("dst"), SyncOptions{Checksum: true})
+	correct := []SyncChange{{"a", SyncUpdated}}
+	if err != nil || !reflect.DeepEqual(changes, correct) {
+		t.Errorf("[fileutils]Sync test failed, expecting %v, got %v, err %v", correct, changes, err)
+	}
+	if b, _ := ioutil.ReadFile(tree.Path("dst/a")); string(b) != "new" {
+		t.Errorf("[fileutils]Sync test failed, expecting \"new\", got %q", b)
+	}
+}
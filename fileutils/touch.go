@@ -0,0 +1,286 @@
+package fileutils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marguerite/go-stdlib/dir"
+	"github.com/marguerite/go-stdlib/extglob"
+	"github.com/marguerite/go-stdlib/internal"
+)
+
+// TouchOptions options for TouchWithOptions
+type TouchOptions struct {
+	// Time the time to set, now if zero, like `touch -t`
+	Time time.Time
+	// Date a date string for ParseDate like "2 hours ago", like `touch -d`. relative
+	// dates are counted from Time, or from the times of the Reference file.
+	Date string
+	// Reference use the times of this file, like `touch -r`
+	Reference string
+	// AccessOnly only change the access time, like `touch -a`
+	AccessOnly bool
+	// ModifyOnly only change the modification time, like `touch -m`
+	ModifyOnly bool
+	// NoCreate do not create missing files, like `touch -c`
+	NoCreate bool
+	// NoDereference change the times of symlinks themselves, like `touch -h`.
+	// missing files are not created then.
+	NoDereference bool
+}
+
+// TouchWithOptions like Linux's touch command, change the access and modification
+// times of files matching the extglob pattern. a missing plain path is created as
+// an empty file, along with its missing parent directories. a pattern matching
+// nothing is an error, unless NoCreate.
+func TouchWithOptions(pattern string, opts TouchOptions) error {
+	atime, mtime, err := touchTimes(opts)
+	if err != nil {
+		return err
+	}
+
+	paths := []string{pattern}
+	if extglob.IsPattern(internal.Str2bytes(pattern), true) {
+		paths, err = extglob.Expand(internal.Str2bytes(pattern))
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 && !opts.NoCreate {
+			return &os.PathError{Op: "touch", Path: pattern, Err: os.ErrNotExist}
+		}
+	}
+
+	for _, p := range paths {
+		err := touch(p, atime, mtime, opts)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// touchTimes the access and modification times to set, a zero time is left unchanged
+func touchTimes(opts TouchOptions) (atime, mtime time.Time, err error) {
+	now := time.Now()
+	atime, mtime = now, now
+	if !opts.Time.IsZero() {
+		atime, mtime = opts.Time, opts.Time
+	}
+
+	if len(opts.Reference) > 0 {
+		fi, err := statLink(opts.Reference, opts.NoDereference)
+		if err != nil {
+			return atime, mtime, err
+		}
+		atime, mtime = internal.Atime(fi), fi.ModTime()
+	}
+
+	if len(opts.Date) > 0 {
+		atime, err = ParseDate(opts.Date, atime)
+		if err != nil {
+			return atime, mtime, err
+		}
+		if len(opts.Reference) == 0 {
+			mtime = atime
+		} else {
+			mtime, err = ParseDate(opts.Date, mtime)
+			if err != nil {
+				return atime, mtime, err
+			}
+		}
+	}
+
+	if opts.AccessOnly && !opts.ModifyOnly {
+		mtime = time.Time{}
+	}
+	if opts.ModifyOnly && !opts.AccessOnly {
+		atime = time.Time{}
+	}
+	return atime, mtime, nil
+}
+
+func touch(path string, atime, mtime time.Time, opts TouchOptions) error {
+	_, err := statLink(path, opts.NoDereference)
+	if os.IsNotExist(err) {
+		if opts.NoCreate {
+			return nil
+		}
+		if opts.NoDereference {
+			return err
+		}
+		_, err = dir.MkdirPWithOptions(filepath.Dir(path), dir.MkdirOptions{})
+		if err != nil {
+			return err
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0666)
+		if err != nil {
+			return err
+		}
+		err = f.Close()
+		if err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	return internal.Utimes(path, atime, mtime, opts.NoDereference)
+}
+
+// the layouts ParseDate accepts for absolute dates, in the local time zone unless given
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.UnixDate,
+}
+
+// ParseDate parse a date string like `date -d` does, base is the time relative
+// dates are counted from. it understands absolute dates like "2021-06-30 12:00"
+// or "@1625054400", the words "now", "today", "yesterday" and "tomorrow", and
+// relative items like "2 hours ago", "+1 day", "last week" or "next month",
+// which can follow an absolute date, like "2021-06-30 +1 week".
+func ParseDate(s string, base time.Time) (time.Time, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return base, fmt.Errorf("invalid date %q", s)
+	}
+
+	if len(fields) == 1 && strings.HasPrefix(fields[0], "@") {
+		t, err := parseEpoch(fields[0][1:])
+		if err != nil {
+			return base, fmt.Errorf("invalid date %q", s)
+		}
+		return t, nil
+	}
+
+	t := base
+	rest := fields
+	n := len(fields)
+	if n > 2 {
+		n = 2
+	}
+	// the date and time of an absolute date can be separate fields
+abs:
+	for ; n > 0; n-- {
+		for _, layout := range dateLayouts {
+			v, err := time.ParseInLocation(layout, strings.Join(fields[:n], " "), time.Local)
+			if err == nil {
+				t, rest = v, fields[n:]
+				break abs
+			}
+		}
+	}
+
+	for i := 0; i < len(rest); i++ {
+		word := strings.ToLower(rest[i])
+		switch word {
+		case "now", "today":
+			continue
+		case "yesterday":
+			t = t.AddDate(0, 0, -1)
+			continue
+		case "tomorrow":
+			t = t.AddDate(0, 0, 1)
+			continue
+		}
+
+		count, counted := 1, true
+		switch word {
+		case "last":
+			count = -1
+		case "next":
+			count = 1
+		default:
+			v, err := strconv.Atoi(word)
+			if err != nil {
+				counted = false
+			} else {
+				count = v
+			}
+		}
+		if counted {
+			i++
+			if i == len(rest) {
+				return base, fmt.Errorf("invalid date %q: missing unit after %q", s, word)
+			}
+			word = strings.ToLower(rest[i])
+		}
+		// "ago" reverses the item it follows
+		if i+1 < len(rest) && strings.ToLower(rest[i+1]) == "ago" {
+			count = -count
+			i++
+		}
+
+		switch strings.TrimSuffix(word, "s") {
+		case "year":
+			t = t.AddDate(count, 0, 0)
+		case "month":
+			t = t.AddDate(0, count, 0)
+		case "fortnight":
+			t = t.AddDate(0, 0, 14*count)
+		case "week":
+			t = t.AddDate(0, 0, 7*count)
+		case "day":
+			t = t.AddDate(0, 0, count)
+		case "hour":
+			t = t.Add(time.Duration(count) * time.Hour)
+		case "minute", "min":
+			t = t.Add(time.Duration(count) * time.Minute)
+		case "second", "sec":
+			t = t.Add(time.Duration(count) * time.Second)
+		default:
+			return base, fmt.Errorf("invalid date %q: unknown item %q", s, word)
+		}
+	}
+	return t, nil
+}
+
+// parseEpoch parse seconds since the epoch like "1625054400.5". the integer and
+// fraction parts are parsed separately, so no precision is lost to a float.
+func parseEpoch(s string) (time.Time, error) {
+	sign := int64(1)
+	if strings.HasPrefix(s, "-") {
+		sign, s = -1, s[1:]
+	} else {
+		s = strings.TrimPrefix(s, "+")
+	}
+
+	i := strings.IndexByte(s, '.')
+	frac := ""
+	if i >= 0 {
+		s, frac = s[:i], s[i+1:]
+	}
+	if len(s) == 0 || s[0] == '+' || s[0] == '-' {
+		return time.Time{}, strconv.ErrSyntax
+	}
+	secs, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var nsecs int64
+	for j := 0; j < 9; j++ {
+		nsecs *= 10
+		if j < len(frac) {
+			if frac[j] < '0' || frac[j] > '9' {
+				return time.Time{}, strconv.ErrSyntax
+			}
+			nsecs += int64(frac[j] - '0')
+		}
+	}
+	// digits beyond nanoseconds are dropped, they must still be digits
+	for j := 9; j < len(frac); j++ {
+		if frac[j] < '0' || frac[j] > '9' {
+			return time.Time{}, strconv.ErrSyntax
+		}
+	}
+	return time.Unix(sign*secs, sign*nsecs), nil
+}
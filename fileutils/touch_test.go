@@ -0,0 +1,135 @@
+package fileutils
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/marguerite/go-stdlib/dir"
+	"github.com/marguerite/go-stdlib/internal"
+)
+
+func TestParseDate(t *testing.T) {
+	base := time.Date(2021, 6, 30, 12, 0, 0, 0, time.Local)
+	tests := map[string]time.Time{
+		"now":                   base,
+		"2 hours ago":           base.Add(-2 * time.Hour),
+		"+1 day":                base.AddDate(0, 0, 1),
+		"yesterday":             base.AddDate(0, 0, -1),
+		"last week":             base.AddDate(0, 0, -7),
+		"next month":            time.Date(2021, 7, 30, 12, 0, 0, 0, time.Local),
+		"1 day 30 minutes ago":  base.AddDate(0, 0, 1).Add(-30 * time.Minute),
+		"2020-01-02 03:04":      time.Date(2020, 1, 2, 3, 4, 0, 0, time.Local),
+		"2020-01-02 +1 week":    time.Date(2020, 1, 9, 0, 0, 0, 0, time.Local),
+		"2020-01-02T03:04:05Z":  time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		"@1625054400":           time.Unix(1625054400, 0),
+		"@1625054400.123456789": time.Unix(1625054400, 123456789),
+		"@-1.5":                 time.Unix(-2, 500000000),
+		"2020-01-02 3 days ago": time.Date(2019, 12, 30, 0, 0, 0, 0, time.Local),
+	}
+	for s, correct := range tests {
+		v, err := ParseDate(s, base)
+		if err != nil || !v.Equal(correct) {
+			t.Errorf("[fileutils]ParseDate test failed for %q, expecting %v, got %v, err %v", s, correct, v, err)
+		}
+	}
+
+	for _, s := range []string{"", "2 parsecs ago", "3", "@", "@1.2.3", "@.5", "@--1", "@1e9"} {
+		_, err := ParseDate(s, base)
+		if err == nil {
+			t.Errorf("[fileutils]ParseDate test failed for %q, expecting an error, got nil", s)
+		}
+	}
+}
+
+func TestTouchWithOptions(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "a.log", Mode: 0644},
+		{Path: "b.log", Mode: 0644},
+		{Path: "ref", Mode: 0644},
+		{Path: "link", Mode: os.ModeSymlink, Target: "a.log"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	past := time.Date(2020, 1, 2, 3, 4, 5, 0, time.Local)
+	err = TouchWithOptions(tree.Path("*.log"), TouchOptions{Time: past})
+	if err != nil {
+		t.Fatalf("[fileutils]TouchWithOptions test failed, expecting nil, got %v", err)
+	}
+	for _, v := range []string{"a.log", "b.log"} {
+		fi, err := os.Stat(tree.Path(v))
+		if err != nil || !fi.ModTime().Equal(past) || !internal.Atime(fi).Equal(past) {
+			t.Errorf("[fileutils]TouchWithOptions test failed, expecting %v, got %v, err %v", past, fi.ModTime(), err)
+		}
+	}
+
+	err = os.Chtimes(tree.Path("ref"), past, past)
+	if err != nil {
+		t.Fatalf("[fileutils]Chtimes failed: %v", err)
+	}
+	err = TouchWithOptions(tree.Path("b.log"), TouchOptions{Reference: tree.Path("ref"), Date: "1 hour ago", ModifyOnly: true})
+	if err != nil {
+		t.Fatalf("[fileutils]TouchWithOptions test failed, expecting nil, got %v", err)
+	}
+	fi, err := os.Stat(tree.Path("b.log"))
+	if correct := past.Add(-time.Hour); err != nil || !fi.ModTime().Equal(correct) || !internal.Atime(fi).Equal(past) {
+		t.Errorf("[fileutils]TouchWithOptions test failed, expecting %v, got %v, err %v", correct, fi.ModTime(), err)
+	}
+
+	err = TouchWithOptions(tree.Path("link"), TouchOptions{NoDereference: true})
+	if err != nil {
+		t.Fatalf("[fileutils]TouchWithOptions test failed, expecting nil, got %v", err)
+	}
+	li, err := os.Lstat(tree.Path("link"))
+	if err != nil || !li.ModTime().After(past) {
+		t.Errorf("[fileutils]TouchWithOptions test failed, expecting the symlink touched, got %v, err %v", li.ModTime(), err)
+	}
+	fi, err = os.Stat(tree.Path("a.log"))
+	if err != nil || !fi.ModTime().Equal(past) {
+		t.Errorf("[fileutils]TouchWithOptions test failed, expecting the target untouched at %v, got %v, err %v", past, fi.ModTime(), err)
+	}
+
+	err = TouchWithOptions(tree.Path("missing"), TouchOptions{NoCreate: true})
+	if _, err1 := os.Lstat(tree.Path("missing")); err != nil || !os.IsNotExist(err1) {
+		t.Errorf("[fileutils]TouchWithOptions test failed, expecting nothing created, got %v, err %v", err1, err)
+	}
+
+	err = TouchWithOptions(tree.Path("*.none"), TouchOptions{})
+	if !os.IsNotExist(err) {
+		t.Errorf("[fileutils]TouchWithOptions test failed, expecting an empty match to be an error, got %v", err)
+	}
+	err = TouchWithOptions(tree.Path("*.none"), TouchOptions{NoCreate: true})
+	if err != nil {
+		t.Errorf("[fileutils]TouchWithOptions NoCreate test failed, expecting nil for an empty match, got %v", err)
+	}
+}
+
+func TestTouchExisting(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "a", Mode: 0644, Content: "a"},
+	})
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	past := time.Now().Add(-time.Hour)
+	err = os.Chtimes(tree.Path("a"), past, past)
+	if err != nil {
+		t.Fatalf("[fileutils]Chtimes failed: %v", err)
+	}
+
+	for _, v := range []string{"a", "b"} {
+		err = Touch(tree.Path(v))
+		if err != nil {
+			t.Fatalf("[fileutils]Touch test failed, expecting nil, got %v", err)
+		}
+		fi, err := os.Stat(tree.Path(v))
+		if err != nil || !fi.ModTime().After(past) {
+			t.Errorf("[fileutils]Touch test failed, expecting a time after %v, got %v, err %v", past, fi, err)
+		}
+	}
+}
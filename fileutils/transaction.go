@@ -0,0 +1,490 @@
+package fileutils
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/marguerite/go-stdlib/dir"
+	"github.com/marguerite/go-stdlib/internal"
+)
+
+var (
+	ErrPendingJournal  = errors.New("the journal holds an unfinished transaction, Recover it first")
+	ErrTransactionDone = errors.New("the transaction is already committed or rolled back")
+	ErrForeignPath     = errors.New("the path holds something the transaction may not have made")
+)
+
+// the journal file inside the journal directory, and the directory of stashed originals
+const (
+	txJournalFile = "journal"
+	txStashDir    = "stash"
+)
+
+// the operations of a Transaction
+const (
+	txCopy    = "copy"
+	txMove    = "move"
+	txMkdir   = "mkdir"
+	txSymlink = "symlink"
+	txRemove  = "remove"
+	txTouch   = "touch"
+	// txMade not an operation, but the marker of what an operation made
+	txMade = "made"
+)
+
+// txOp an operation of a Transaction and what is needed to undo it,
+// journaled before the operation is carried out
+type txOp struct {
+	Seq    int    `json:"seq"`
+	Op     string `json:"op"`
+	Path   string `json:"path"`
+	Source string `json:"source,omitempty"`
+	// Existed whether Path existed, it is stashed then
+	Existed bool `json:"existed,omitempty"`
+	// Created the directories mkdir creates, from top to bottom
+	Created []string `json:"created,omitempty"`
+	// Atime/Mtime the times of a touched file that existed
+	Atime time.Time `json:"atime"`
+	Mtime time.Time `json:"mtime"`
+	// Made what copy and symlink made at Path, journaled in a txMade record once they are carried out
+	Made *txMarker `json:"made,omitempty"`
+
+	opts CopyOptions
+	err  error
+	// started whether it was carried out in this process, so what is at Path is its own
+	started bool
+}
+
+// txMarker tells a file from what may replace it: the inode of a removed file is soon reused
+type txMarker struct {
+	ID   internal.FileID `json:"id"`
+	Type os.FileMode     `json:"type"`
+}
+
+func markerOf(fi os.FileInfo) (txMarker, bool) {
+	id, _, ok := internal.StatID(fi)
+	return txMarker{ID: id, Type: fi.Mode() & os.ModeType}, ok
+}
+
+// Transaction a set of file operations applied all or nothing. operations are
+// planned first, then carried out by Execute, which stashes every path they
+// replace or remove in the journal directory. Commit drops the stash, Rollback
+// undoes the operations carried out and restores the stash. the journal is
+// synced before each operation, so after a crash Recover rolls it back.
+// the journal directory is best on the filesystem of the paths, so stashing is a rename.
+type Transaction struct {
+	journal string
+	ops     []*txOp
+	// logged the number of operations journaled, which may have been carried out
+	logged int
+	f      *os.File
+	done   bool
+}
+
+// NewTransaction start a transaction journaled in the directory journal, which
+// is created if missing. it fails with ErrPendingJournal if the journal holds
+// a transaction interrupted by a crash.
+func NewTransaction(journal string) (*Transaction, error) {
+	journal, err := filepath.Abs(journal)
+	if err != nil {
+		return nil, err
+	}
+	_, err = dir.MkdirPWithOptions(journal, dir.MkdirOptions{Mode: 0700})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Lstat(filepath.Join(journal, txJournalFile)); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrPendingJournal, journal)
+	}
+	// a crash may have left the stash of a finished transaction
+	err = os.RemoveAll(filepath.Join(journal, txStashDir))
+	if err != nil {
+		return nil, err
+	}
+	return &Transaction{journal: journal}, nil
+}
+
+// Recover roll back the transaction a crash left in the directory journal, if any.
+// it fails with ErrForeignPath, leaving the path alone, where something may have
+// been put by someone else since.
+func Recover(journal string) error {
+	journal, err := filepath.Abs(journal)
+	if err != nil {
+		return err
+	}
+	ops, err := readJournal(filepath.Join(journal, txJournalFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	tx := &Transaction{journal: journal, ops: ops, logged: len(ops)}
+	return tx.Rollback()
+}
+
+// readJournal read the operations in the journal file, a line cut short by a crash is ignored
+func readJournal(path string) ([]*txOp, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ops []*txOp
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		op := &txOp{}
+		if json.Unmarshal(scanner.Bytes(), op) != nil {
+			break
+		}
+		if op.Op == txMade {
+			if op.Seq < len(ops) {
+				ops[op.Seq].Made = op.Made
+			}
+			continue
+		}
+		ops = append(ops, op)
+	}
+	return ops, scanner.Err()
+}
+
+func (tx *Transaction) plan(op *txOp) {
+	op.Seq = len(tx.ops)
+	var err error
+	op.Path, err = filepath.Abs(op.Path)
+	if err == nil && len(op.Source) > 0 && op.Op != txSymlink {
+		op.Source, err = filepath.Abs(op.Source)
+	}
+	op.err = err
+	tx.ops = append(tx.ops, op)
+}
+
+// Copy plan to copy src to dest. unlike CopyWithOptions, dest is the path
+// of the copy, never a directory to copy into. an existing dest is replaced.
+func (tx *Transaction) Copy(src, dest string, opts CopyOptions) {
+	tx.plan(&txOp{Op: txCopy, Path: dest, Source: src, opts: opts})
+}
+
+// Move plan to move src to dest, an existing dest is replaced
+func (tx *Transaction) Move(src, dest string) {
+	tx.plan(&txOp{Op: txMove, Path: dest, Source: src})
+}
+
+// Mkdir plan to create path and its missing parents like dir.MkdirP
+func (tx *Transaction) Mkdir(path string) {
+	tx.plan(&txOp{Op: txMkdir, Path: path})
+}
+
+// Symlink plan to make link a symlink to target, an existing link is replaced
+func (tx *Transaction) Symlink(target, link string) {
+	tx.plan(&txOp{Op: txSymlink, Path: link, Source: target})
+}
+
+// Remove plan to remove path, a directory with its contents
+func (tx *Transaction) Remove(path string) {
+	tx.plan(&txOp{Op: txRemove, Path: path})
+}
+
+// Touch plan to touch path like Touch, its previous times are restored on rollback.
+// its parent directory must exist by then.
+func (tx *Transaction) Touch(path string) {
+	tx.plan(&txOp{Op: txTouch, Path: path})
+}
+
+// Execute carry out the planned operations in order, stopping at the first
+// failure, after which Rollback is expected
+func (tx *Transaction) Execute() error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+	for _, op := range tx.ops[tx.logged:] {
+		if op.err != nil {
+			return op.err
+		}
+		err := tx.apply(op)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", op.Op, op.Path, err)
+		}
+	}
+	return nil
+}
+
+func (tx *Transaction) stash(op *txOp) string {
+	return filepath.Join(tx.journal, txStashDir, strconv.Itoa(op.Seq))
+}
+
+// apply journal op with what undoes it, then carry it out
+func (tx *Transaction) apply(op *txOp) error {
+	switch op.Op {
+	case txMkdir:
+		for p := filepath.Clean(op.Path); ; p = filepath.Dir(p) {
+			if _, err := os.Lstat(p); err == nil || p == filepath.Dir(p) {
+				break
+			}
+			op.Created = append([]string{p}, op.Created...)
+		}
+	case txTouch:
+		if fi, err := os.Stat(op.Path); err == nil {
+			op.Existed = true
+			op.Atime, op.Mtime = internal.Atime(fi), fi.ModTime()
+		}
+	default:
+		_, err := os.Lstat(op.Path)
+		switch {
+		case err == nil:
+			op.Existed = true
+		case !os.IsNotExist(err) || op.Op == txRemove:
+			return err
+		}
+	}
+
+	err := tx.log(op)
+	if err != nil {
+		return err
+	}
+	op.started = true
+
+	if op.Existed && op.Op != txTouch {
+		_, err = dir.MkdirPWithOptions(filepath.Join(tx.journal, txStashDir), dir.MkdirOptions{Mode: 0700})
+		if err != nil {
+			return err
+		}
+		err = relocate(op.Path, tx.stash(op))
+		if err != nil {
+			return err
+		}
+	}
+
+	switch op.Op {
+	case txCopy:
+		c := newCopier(op.opts)
+		fi, err := c.stat(op.Source)
+		if err != nil {
+			return err
+		}
+		err = c.walk(op.Source, op.Path, fi)
+		if err != nil {
+			return err
+		}
+		err = c.finish()
+		if err != nil {
+			return err
+		}
+		return tx.made(op)
+	case txMove:
+		return relocate(op.Source, op.Path)
+	case txMkdir:
+		_, err = dir.MkdirPWithOptions(op.Path, dir.MkdirOptions{})
+		return err
+	case txSymlink:
+		err = os.Symlink(op.Source, op.Path)
+		if err != nil {
+			return err
+		}
+		return tx.made(op)
+	case txTouch:
+		// missing parents would not be undone, they are for Mkdir
+		if _, err := os.Stat(filepath.Dir(op.Path)); err != nil {
+			return err
+		}
+		return Touch(op.Path)
+	}
+	// remove is done by the stashing
+	return nil
+}
+
+// made journal the marker of what op made at its path, so a rollback after
+// a crash tells it from what someone else put there
+func (tx *Transaction) made(op *txOp) error {
+	fi, err := os.Lstat(op.Path)
+	if err != nil {
+		return err
+	}
+	m, ok := markerOf(fi)
+	if !ok {
+		return nil
+	}
+	op.Made = &m
+	return tx.write(&txOp{Seq: op.Seq, Op: txMade, Made: &m})
+}
+
+// log append op to the journal and sync it
+func (tx *Transaction) log(op *txOp) error {
+	err := tx.write(op)
+	if err != nil {
+		return err
+	}
+	tx.logged++
+	return nil
+}
+
+// write append the record rec to the journal and sync it
+func (tx *Transaction) write(rec *txOp) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	if tx.f == nil {
+		tx.f, err = os.OpenFile(filepath.Join(tx.journal, txJournalFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+		err = syncDir(tx.journal)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.f.Write(append(b, '\n'))
+	if err != nil {
+		return err
+	}
+	return tx.f.Sync()
+}
+
+// relocate move source to target, which does not exist, even across filesystems
+func relocate(source, target string) error {
+	si, err := os.Lstat(source)
+	if err != nil {
+		return err
+	}
+	err = rename(source, target)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	return moveAcross(newCopier(CopyOptions{Archive: true}), source, target, si)
+}
+
+// undo undo op, whether it was carried out fully, partly or not at all
+func (tx *Transaction) undo(op *txOp) error {
+	switch op.Op {
+	case txMkdir:
+		for i := len(op.Created) - 1; i >= 0; i-- {
+			err := os.Remove(op.Created[i])
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	case txTouch:
+		if !op.Existed {
+			err := os.Remove(op.Path)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return nil
+		}
+		return internal.Utimes(op.Path, op.Atime, op.Mtime, false)
+	}
+
+	stash := tx.stash(op)
+	_, err := os.Lstat(stash)
+	stashed := err == nil
+	if op.Existed && !stashed {
+		// interrupted before anything changed
+		return nil
+	}
+
+	if op.Op == txMove {
+		_, err1 := os.Lstat(op.Source)
+		_, err2 := os.Lstat(op.Path)
+		if os.IsNotExist(err1) && err2 == nil {
+			err := relocate(op.Path, op.Source)
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		err := unmake(op)
+		if err != nil {
+			return err
+		}
+	}
+
+	if stashed {
+		return relocate(stash, op.Path)
+	}
+	return nil
+}
+
+// unmake remove what op made at its path. it is known to be op's by the marker,
+// its inode and type, journaled once op was carried out, or by op being carried out in this process.
+// otherwise op was interrupted by a crash, and only a non-directory is removed:
+// a directory may be anyone's, it fails with ErrForeignPath as does a marker mismatch.
+func unmake(op *txOp) error {
+	fi, err := os.Lstat(op.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// remove makes nothing
+	if op.Op == txRemove {
+		return &os.PathError{Op: "rollback", Path: op.Path, Err: ErrForeignPath}
+	}
+
+	made := op.started
+	if op.Made != nil {
+		m, ok := markerOf(fi)
+		made = ok && m == *op.Made
+	}
+	switch {
+	case made:
+		return os.RemoveAll(op.Path)
+	case op.Made == nil && !fi.IsDir():
+		return os.Remove(op.Path)
+	}
+	return &os.PathError{Op: "rollback", Path: op.Path, Err: ErrForeignPath}
+}
+
+// Commit keep the changes and drop the stashed originals
+func (tx *Transaction) Commit() error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+	tx.done = true
+	return tx.clear()
+}
+
+// Rollback undo the operations carried out, last first, and restore the stashed originals
+func (tx *Transaction) Rollback() error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+	for i := tx.logged - 1; i >= 0; i-- {
+		err := tx.undo(tx.ops[i])
+		if err != nil {
+			return fmt.Errorf("rolling back %s %s: %w", tx.ops[i].Op, tx.ops[i].Path, err)
+		}
+	}
+	tx.done = true
+	return tx.clear()
+}
+
+// clear remove the journal, which ends the transaction even if a crash follows,
+// then the stash
+func (tx *Transaction) clear() error {
+	if tx.f != nil {
+		tx.f.Close()
+		tx.f = nil
+	}
+	err := os.Remove(filepath.Join(tx.journal, txJournalFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = syncDir(tx.journal)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(tx.journal, txStashDir))
+}
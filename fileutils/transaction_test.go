@@ -0,0 +1,177 @@
+package fileutils
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/marguerite/go-stdlib/dir"
+)
+
+var txSpec = dir.TreeSpec{
+	{Path: "app/bin/app", Mode: 0755, Content: "v1"},
+	{Path: "app/current", Mode: os.ModeSymlink, Target: "bin/app"},
+	{Path: "app/old.conf", Mode: 0644, Content: "old"},
+	{Path: "new/app", Mode: 0755, Content: "v2"},
+	{Path: "new/app.conf", Mode: 0644, Content: "conf"},
+}
+
+// planTx plan an upgrade of the tree of txSpec
+func planTx(tx *Transaction, tree *dir.Tree) {
+	tx.Mkdir(tree.Path("app/etc/app"))
+	tx.Move(tree.Path("new/app.conf"), tree.Path("app/etc/app/app.conf"))
+	tx.Copy(tree.Path("new/app"), tree.Path("app/bin/app"), CopyOptions{PreserveMode: true})
+	tx.Symlink("etc/app/app.conf", tree.Path("app/current"))
+	tx.Remove(tree.Path("app/old.conf"))
+	tx.Touch(tree.Path("app/stamp"))
+}
+
+func TestTransactionCommit(t *testing.T) {
+	tree, err := dir.TempTree(txSpec)
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	tx, err := NewTransaction(tree.Path("journal"))
+	if err != nil {
+		t.Fatalf("[fileutils]NewTransaction test failed, expecting nil, got %v", err)
+	}
+	planTx(tx, tree)
+	err = tx.Execute()
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		t.Fatalf("[fileutils]Transaction test failed, expecting nil, got %v", err)
+	}
+
+	spec, err := tree.Snapshot()
+	correct := dir.TreeSpec{
+		{Path: "app", Mode: os.ModeDir | 0755},
+		{Path: "app/bin", Mode: os.ModeDir | 0755},
+		{Path: "app/bin/app", Mode: 0755, Content: "v2"},
+		{Path: "app/current", Mode: os.ModeSymlink, Target: "etc/app/app.conf"},
+		{Path: "app/etc", Mode: os.ModeDir | 0755},
+		{Path: "app/etc/app", Mode: os.ModeDir | 0755},
+		{Path: "app/etc/app/app.conf", Mode: 0644, Content: "conf"},
+		{Path: "app/stamp", Mode: 0644},
+		{Path: "journal", Mode: os.ModeDir | 0700},
+		{Path: "new", Mode: os.ModeDir | 0755},
+		{Path: "new/app", Mode: 0755, Content: "v2"},
+	}
+	if err != nil || !reflect.DeepEqual(spec, correct) {
+		t.Errorf("[fileutils]Transaction test failed, expecting %v, got %v, err %v", correct, spec, err)
+	}
+
+	if err = tx.Rollback(); !errors.Is(err, ErrTransactionDone) {
+		t.Errorf("[fileutils]Transaction test failed, expecting %v, got %v", ErrTransactionDone, err)
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	tree, err := dir.TempTree(txSpec)
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	tx, err := NewTransaction(tree.Path("journal"))
+	if err != nil {
+		t.Fatalf("[fileutils]NewTransaction test failed, expecting nil, got %v", err)
+	}
+	before, _ := tree.Snapshot()
+	planTx(tx, tree)
+	// fails after everything else is done
+	tx.Remove(tree.Path("missing"))
+	err = tx.Execute()
+	if !os.IsNotExist(errors.Unwrap(err)) {
+		t.Fatalf("[fileutils]Transaction test failed, expecting not exist, got %v", err)
+	}
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatalf("[fileutils]Transaction test failed, expecting nil, got %v", err)
+	}
+
+	spec, err := tree.Snapshot()
+	if correct := before; err != nil || !reflect.DeepEqual(spec, correct) {
+		t.Errorf("[fileutils]Transaction test failed, expecting %v, got %v, err %v", correct, spec, err)
+	}
+}
+
+func TestTransactionRecover(t *testing.T) {
+	tree, err := dir.TempTree(txSpec)
+	if err != nil {
+		t.Fatalf("[fileutils]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	tx, err := NewTransaction(tree.Path("journal"))
+	if err != nil {
+		t.Fatalf("[fileutils]NewTransaction test failed, expecting nil, got %v", err)
+	}
+	before, _ := tree.Snapshot()
+	planTx(tx, tree)
+	err = tx.Execute()
+	if err != nil {
+		t.Fatalf("[fileutils]Transaction test failed, expecting nil, got %v", err)
+	}
+	// crash
+	tx.f.Close()
+
+	_, err = NewTransaction(tree.Path("journal"))
+	if !errors.Is(err, ErrPendingJournal) {
+		t.Errorf("[fileutils]NewTransaction test failed, expecting %v, got %v", ErrPendingJournal, err)
+	}
+	err = Recover(tree.Path("journal"))
+	if err != nil {
+		t.Fatalf("[fileutils]Recover test failed, expecting nil, got %v", err)
+	}
+
+	spec, err := tree.Snapshot()
+	if correct := before; err != nil || !reflect.DeepEqual(spec, correct) {
+		t.Errorf("[fileutils]Recover test failed, expecting %v, got %v, err %v", correct, spec, err)
+	}
+}
+
+func TestTransactionRecoverForeign(t *testing.T) {
+	// whether the symlink is carried out before the crash, after which someone
+	// else puts a directory where the transaction made or was to make something
+	for _, carried := range []bool{true, false} {
+		tree, err := dir.TempTree(txSpec)
+		if err != nil {
+			t.Fatalf("[fileutils]TempTree failed: %v", err)
+		}
+		defer tree.Cleanup()
+
+		tx, err := NewTransaction(tree.Path("journal"))
+		if err != nil {
+			t.Fatalf("[fileutils]NewTransaction test failed, expecting nil, got %v", err)
+		}
+		tx.Symlink("bin/app", tree.Path("app/link"))
+		if carried {
+			err = tx.apply(tx.ops[0])
+		} else {
+			err = tx.log(tx.ops[0])
+		}
+		if err != nil {
+			t.Fatalf("[fileutils]Transaction test failed, expecting nil, got %v", err)
+		}
+		tx.f.Close()
+
+		os.Remove(tree.Path("app/link"))
+		err = dir.MkdirP(tree.Path("app/link/data"))
+		if err != nil {
+			t.Fatalf("[fileutils]MkdirP failed: %v", err)
+		}
+
+		err = Recover(tree.Path("journal"))
+		if !errors.Is(err, ErrForeignPath) {
+			t.Errorf("[fileutils]Recover test failed, expecting %v, got %v", ErrForeignPath, err)
+		}
+		if _, err := os.Stat(tree.Path("app/link/data")); err != nil {
+			t.Errorf("[fileutils]Recover test failed, expecting app/link/data kept, got %v", err)
+		}
+	}
+}
@@ -3,7 +3,9 @@ module github.com/marguerite/go-stdlib
 go 1.15
 
 require (
+	github.com/klauspost/compress v1.13.1
 	github.com/marguerite/go-gnulib v0.0.0-20210318090450-407d620c3bb7
-	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c // indirect
+	github.com/ulikunitz/xz v0.5.10
+	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c
 	golang.org/x/text v0.3.6
 )
@@ -0,0 +1,18 @@
+// +build darwin freebsd netbsd
+
+package internal
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// Atime the access time of fi, the modification time if unknown
+func Atime(fi os.FileInfo) time.Time {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fi.ModTime()
+	}
+	return time.Unix(st.Atimespec.Unix())
+}
@@ -0,0 +1,7 @@
+package internal
+
+// FileID identifies a file by its device and inode number
+type FileID struct {
+	Dev uint64
+	Ino uint64
+}
@@ -0,0 +1,51 @@
+// +build !linux,!freebsd,!openbsd,!netbsd,!darwin
+
+package internal
+
+import (
+	"os"
+	"time"
+)
+
+// StatID the device and inode of fi along with its hard link count, unknown here
+func StatID(fi os.FileInfo) (id FileID, nlink uint64, ok bool) {
+	return id, 0, false
+}
+
+// StatOwner the uid and gid of fi, unknown here
+func StatOwner(fi os.FileInfo) (uid, gid int, ok bool) {
+	return -1, -1, false
+}
+
+// Atime the access time of fi, the modification time here
+func Atime(fi os.FileInfo) time.Time {
+	return fi.ModTime()
+}
+
+// Lutimes change the access and modification times of path, symlinks are followed here
+func Lutimes(path string, atime, mtime time.Time) error {
+	return Utimes(path, atime, mtime, false)
+}
+
+// Utimes change the access and modification times of path, a zero time is
+// left unchanged. symlinks are always followed here.
+func Utimes(path string, atime, mtime time.Time, nofollow bool) error {
+	if atime.IsZero() || mtime.IsZero() {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if atime.IsZero() {
+			atime = Atime(fi)
+		}
+		if mtime.IsZero() {
+			mtime = fi.ModTime()
+		}
+	}
+	return os.Chtimes(path, atime, mtime)
+}
+
+// Umask the file mode creation mask of the process, none here
+func Umask() os.FileMode {
+	return 0
+}
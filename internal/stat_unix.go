@@ -0,0 +1,72 @@
+// +build linux freebsd openbsd netbsd darwin
+
+package internal
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// StatID the device and inode of fi along with its hard link count
+func StatID(fi os.FileInfo) (id FileID, nlink uint64, ok bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return id, 0, false
+	}
+	return FileID{Dev: uint64(st.Dev), Ino: uint64(st.Ino)}, uint64(st.Nlink), true
+}
+
+// StatOwner the uid and gid of fi
+func StatOwner(fi os.FileInfo) (uid, gid int, ok bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return -1, -1, false
+	}
+	return int(st.Uid), int(st.Gid), true
+}
+
+// Lutimes change the access and modification times of path without following symlinks
+func Lutimes(path string, atime, mtime time.Time) error {
+	return Utimes(path, atime, mtime, true)
+}
+
+// Utimes change the access and modification times of path, a zero time is
+// left unchanged. symlinks are followed unless nofollow.
+func Utimes(path string, atime, mtime time.Time, nofollow bool) error {
+	op := "utimes"
+	flags := 0
+	if nofollow {
+		op = "lutimes"
+		flags = unix.AT_SYMLINK_NOFOLLOW
+	}
+
+	if atime.IsZero() || mtime.IsZero() {
+		// UTIME_OMIT differs between systems, keep the current time instead
+		var fi os.FileInfo
+		var err error
+		if nofollow {
+			fi, err = os.Lstat(path)
+		} else {
+			fi, err = os.Stat(path)
+		}
+		if err != nil {
+			return err
+		}
+		if atime.IsZero() {
+			atime = Atime(fi)
+		}
+		if mtime.IsZero() {
+			mtime = fi.ModTime()
+		}
+	}
+
+	ts := []unix.Timespec{unix.NsecToTimespec(atime.UnixNano()), unix.NsecToTimespec(mtime.UnixNano())}
+	err := unix.UtimesNanoAt(unix.AT_FDCWD, path, ts, flags)
+	if err != nil {
+		return &os.PathError{Op: op, Path: path, Err: err}
+	}
+	return nil
+}
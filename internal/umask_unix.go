@@ -0,0 +1,40 @@
+// +build linux freebsd openbsd netbsd darwin
+
+package internal
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+var umaskMu sync.Mutex
+
+// Umask the file mode creation mask of the process, read from /proc/self/status
+// where the kernel tells it. elsewhere it is read by setting it to 0 and back,
+// which is racy: a file created meanwhile by another goroutine, or by another
+// thread not calling Umask, gets no mask at all.
+func Umask() os.FileMode {
+	// Linux 4.7+ tells it without changing it
+	if f, err := os.Open("/proc/self/status"); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if strings.HasPrefix(scanner.Text(), "Umask:") {
+				v, err := strconv.ParseUint(strings.TrimSpace(scanner.Text()[len("Umask:"):]), 8, 32)
+				if err == nil {
+					return os.FileMode(v)
+				}
+			}
+		}
+	}
+
+	umaskMu.Lock()
+	defer umaskMu.Unlock()
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+	return os.FileMode(mask)
+}
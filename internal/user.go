@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"os/user"
+	"strconv"
+)
+
+// LookupOwner resolve owner and group names (or numeric ids) to uid and gid.
+// an empty owner or group resolves to -1, which os.Chown treats as unchanged.
+func LookupOwner(owner, group string) (uid, gid int, err error) {
+	uid, gid = -1, -1
+
+	if len(owner) > 0 {
+		uid, err = strconv.Atoi(owner)
+		if err != nil {
+			u, err := user.Lookup(owner)
+			if err != nil {
+				return -1, -1, err
+			}
+			uid, err = strconv.Atoi(u.Uid)
+			if err != nil {
+				return -1, -1, err
+			}
+		}
+	}
+
+	if len(group) > 0 {
+		gid, err = strconv.Atoi(group)
+		if err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return -1, -1, err
+			}
+			gid, err = strconv.Atoi(g.Gid)
+			if err != nil {
+				return -1, -1, err
+			}
+		}
+	}
+
+	return uid, gid, nil
+}
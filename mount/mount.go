@@ -0,0 +1,236 @@
+// Package mount the mount table of Linux, as /proc/self/mountinfo tells it,
+// and the space and inodes filesystems have left.
+package mount
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/marguerite/go-stdlib/dir"
+)
+
+// MountInfo the mount table of the current process
+const MountInfo = "/proc/self/mountinfo"
+
+var (
+	// ErrInvalidMountInfo a line of mountinfo is malformed
+	ErrInvalidMountInfo = errors.New("invalid mountinfo")
+	// ErrNoMount no mount holds the path
+	ErrNoMount = errors.New("no mount found")
+)
+
+// Mount a mount, a line of mountinfo, see proc(5)
+type Mount struct {
+	// ID the unique id of the mount, which may be reused once it is unmounted
+	ID int
+	// Parent the id of the parent mount, or of itself at the top of the tree
+	Parent int
+	// Major/Minor the device of the filesystem, st_dev of its files
+	Major int
+	Minor int
+	// Root the directory of the filesystem mounted, "/" unless it is a bind mount or a subvolume
+	Root string
+	// MountPoint where it is mounted, relative to the root directory of the process
+	MountPoint string
+	// Options the per-mount options, eg: "rw", "nosuid" or "relatime"
+	Options []string
+	// Optional the optional fields, eg: "shared:1" or "master:2"
+	Optional []string
+	// FSType the type of the filesystem, eg: "ext4", with its subtype, eg: "fuse.sshfs"
+	FSType string
+	// Source the device or whatever the filesystem is mounted from, "none" if nothing
+	Source string
+	// SuperOptions the per-superblock options, eg: "rw" or "size=1024k"
+	SuperOptions []string
+}
+
+// ReadOnly whether the mount, or the filesystem under it, is read only
+func (m Mount) ReadOnly() bool {
+	return hasOption(m.Options, "ro") || hasOption(m.SuperOptions, "ro")
+}
+
+// Option the value of the per-mount or per-superblock option name, and whether it is set
+func (m Mount) Option(name string) (string, bool) {
+	for _, opts := range [][]string{m.Options, m.SuperOptions} {
+		for _, v := range opts {
+			if v == name {
+				return "", true
+			}
+			if strings.HasPrefix(v, name+"=") {
+				return v[len(name)+1:], true
+			}
+		}
+	}
+	return "", false
+}
+
+func hasOption(opts []string, name string) bool {
+	for _, v := range opts {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Mounts the mount table of the current process, from /proc/self/mountinfo
+func Mounts() ([]Mount, error) {
+	return ParseFile(MountInfo)
+}
+
+// ParseFile parse the mountinfo file path
+func ParseFile(path string) ([]Mount, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse parse mountinfo lines like
+// "36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue"
+func Parse(r io.Reader) ([]Mount, error) {
+	var mounts []Mount
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		m, err := parseLine(line)
+		if err != nil {
+			return mounts, fmt.Errorf("%w: line %d: %s", err, n, line)
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, scanner.Err()
+}
+
+func parseLine(line string) (Mount, error) {
+	var m Mount
+	fields := strings.Fields(line)
+	// the optional fields end with a single "-"
+	sep := -1
+	for i := 6; i < len(fields); i++ {
+		if fields[i] == "-" {
+			sep = i
+			break
+		}
+	}
+	if sep < 0 || len(fields) < sep+3 {
+		return m, ErrInvalidMountInfo
+	}
+
+	var err error
+	m.ID, err = strconv.Atoi(fields[0])
+	if err != nil {
+		return m, ErrInvalidMountInfo
+	}
+	m.Parent, err = strconv.Atoi(fields[1])
+	if err != nil {
+		return m, ErrInvalidMountInfo
+	}
+	dev := strings.Split(fields[2], ":")
+	if len(dev) != 2 {
+		return m, ErrInvalidMountInfo
+	}
+	m.Major, err = strconv.Atoi(dev[0])
+	if err != nil {
+		return m, ErrInvalidMountInfo
+	}
+	m.Minor, err = strconv.Atoi(dev[1])
+	if err != nil {
+		return m, ErrInvalidMountInfo
+	}
+
+	m.Root = unescape(fields[3])
+	m.MountPoint = unescape(fields[4])
+	m.Options = strings.Split(fields[5], ",")
+	m.Optional = fields[6:sep]
+	m.FSType = unescape(fields[sep+1])
+	m.Source = unescape(fields[sep+2])
+	if len(fields) > sep+3 {
+		m.SuperOptions = strings.Split(fields[sep+3], ",")
+	}
+	return m, nil
+}
+
+// unescape undo the octal escapes the kernel writes spaces, tabs, newlines and backslashes as, eg: "\040"
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && isOctal(s[i+1:i+4]) {
+			v, _ := strconv.ParseUint(s[i+1:i+4], 8, 8)
+			b.WriteByte(byte(v))
+			i += 3
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isOctal(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '7' {
+			return false
+		}
+	}
+	// \777 overflows a byte
+	return s[0] <= '3'
+}
+
+// FindMount the mount the file or directory path is on, symlinks resolved.
+// see Lookup to search a table of your own.
+func FindMount(path string) (Mount, error) {
+	mounts, err := Mounts()
+	if err != nil {
+		return Mount{}, err
+	}
+	p, err := dir.Realpath(path, dir.CanonicalizeExisting)
+	if err != nil {
+		return Mount{}, err
+	}
+	m, ok := Lookup(mounts, p)
+	if !ok {
+		return m, &os.PathError{Op: "findmount", Path: path, Err: ErrNoMount}
+	}
+	return m, nil
+}
+
+// Lookup the mount of mounts the canonical absolute path is on: the one with
+// the longest mount point holding it, the last mounted one if it is mounted over.
+func Lookup(mounts []Mount, path string) (Mount, bool) {
+	path = filepath.Clean(path)
+	best := -1
+	for i, m := range mounts {
+		if !within(m.MountPoint, path) {
+			continue
+		}
+		if best < 0 || len(m.MountPoint) >= len(mounts[best].MountPoint) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return Mount{}, false
+	}
+	return mounts[best], true
+}
+
+// within whether path is mountPoint or under it
+func within(mountPoint, path string) bool {
+	if mountPoint == path || mountPoint == "/" {
+		return true
+	}
+	return strings.HasPrefix(path, mountPoint+"/")
+}
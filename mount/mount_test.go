@@ -0,0 +1,82 @@
+package mount
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestParseFile(t *testing.T) {
+	mounts, err := ParseFile("testdata/mountinfo")
+	if err != nil || len(mounts) != 10 {
+		t.Fatalf("[mount]ParseFile test failed, expecting 10 mounts, got %d, err %v", len(mounts), err)
+	}
+
+	correct := Mount{
+		ID:           52,
+		Parent:       40,
+		Major:        0,
+		Minor:        45,
+		Root:         "/",
+		MountPoint:   "/home/user/My Drive",
+		Options:      []string{"rw", "nosuid", "nodev", "relatime"},
+		Optional:     []string{"shared:40", "master:12"},
+		FSType:       "fuse.sshfs",
+		Source:       `user@host:/srv/back\slash`,
+		SuperOptions: []string{"rw", "user_id=1000", "group_id=1000"},
+	}
+	if !reflect.DeepEqual(mounts[7], correct) {
+		t.Errorf("[mount]ParseFile test failed, expecting %+v, got %+v", correct, mounts[7])
+	}
+	if m := mounts[5]; m.Root != "/@/home" || m.Major != 0 || m.Minor != 21 {
+		t.Errorf("[mount]ParseFile test failed, expecting the /@/home subvolume on 0:21, got %+v", m)
+	}
+	if v, ok := mounts[3].Option("nr_inodes"); !ok || v != "1048576" {
+		t.Errorf("[mount]Mount.Option test failed, expecting 1048576, got %s %v", v, ok)
+	}
+	if !mounts[8].ReadOnly() || mounts[9].ReadOnly() {
+		t.Errorf("[mount]Mount.ReadOnly test failed, expecting only the lower /mnt read only")
+	}
+
+	_, err = ParseFile("testdata/mountinfo.invalid")
+	if !errors.Is(err, ErrInvalidMountInfo) {
+		t.Errorf("[mount]ParseFile test failed, expecting ErrInvalidMountInfo, got %v", err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	mounts, err := ParseFile("testdata/mountinfo")
+	if err != nil {
+		t.Fatalf("[mount]ParseFile failed: %v", err)
+	}
+	tests := map[string]int{
+		"/":                          22,
+		"/etc/fstab":                 22,
+		"/dev/shm/x":                 26,
+		"/devices":                   22,
+		"/home":                      40,
+		"/home/user/My Drive/a.txt":  52,
+		"/home/user/My Drive2":       40,
+		"/mnt/file":                  61,
+		"/boot/efi/EFI/BOOT/../BOOT": 41,
+	}
+	for p, id := range tests {
+		if m, ok := Lookup(mounts, p); !ok || m.ID != id {
+			t.Errorf("[mount]Lookup test failed for %s, expecting mount %d, got %d %v", p, id, m.ID, ok)
+		}
+	}
+	if _, ok := Lookup(nil, "/"); ok {
+		t.Errorf("[mount]Lookup test failed, expecting no mount in an empty table")
+	}
+}
+
+func TestFindMount(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("mountinfo is only on Linux")
+	}
+	m, err := FindMount("/proc/self")
+	if err != nil || m.FSType != "proc" {
+		t.Errorf("[mount]FindMount test failed, expecting proc, got %+v, err %v", m, err)
+	}
+}
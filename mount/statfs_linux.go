@@ -0,0 +1,32 @@
+package mount
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Statfs the space and inodes of the filesystem path is on
+func Statfs(path string) (Usage, error) {
+	var st unix.Statfs_t
+	err := unix.Statfs(path, &st)
+	if err != nil {
+		return Usage{}, &os.PathError{Op: "statfs", Path: path, Err: err}
+	}
+
+	// the block counts are in fragments, f_bsize is only the preferred I/O size
+	bsize := uint64(st.Frsize)
+	if bsize == 0 {
+		bsize = uint64(st.Bsize)
+	}
+	return Usage{
+		BlockSize: int64(bsize),
+		Total:     uint64(st.Blocks) * bsize,
+		Free:      uint64(st.Bfree) * bsize,
+		Available: uint64(st.Bavail) * bsize,
+		Used:      (uint64(st.Blocks) - uint64(st.Bfree)) * bsize,
+		Files:     uint64(st.Files),
+		FilesFree: uint64(st.Ffree),
+		FilesUsed: uint64(st.Files) - uint64(st.Ffree),
+	}, nil
+}
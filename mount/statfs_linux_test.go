@@ -0,0 +1,22 @@
+package mount
+
+import (
+	"os"
+	"testing"
+)
+
+func TestStatfs(t *testing.T) {
+	u, err := Statfs(os.TempDir())
+	if err != nil {
+		t.Fatalf("[mount]Statfs test failed: %v", err)
+	}
+	if u.Total == 0 || u.Used > u.Total || u.Available > u.Free || u.BlockSize <= 0 {
+		t.Errorf("[mount]Statfs test failed, expecting consistent sizes, got %+v", u)
+	}
+	if p := u.UsedPercent(); p < 0 || p > 100 {
+		t.Errorf("[mount]Usage.UsedPercent test failed, expecting 0-100, got %f", p)
+	}
+	if _, err := Statfs("/nonexistent"); !os.IsNotExist(err) {
+		t.Errorf("[mount]Statfs test failed, expecting os.ErrNotExist, got %v", err)
+	}
+}
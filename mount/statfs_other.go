@@ -0,0 +1,16 @@
+// +build !linux
+
+package mount
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrNotSupported Statfs is only supported on Linux
+var ErrNotSupported = errors.New("statfs is not supported on this system")
+
+// Statfs the space and inodes of the filesystem path is on, unknown here
+func Statfs(path string) (Usage, error) {
+	return Usage{}, &os.PathError{Op: "statfs", Path: path, Err: ErrNotSupported}
+}
@@ -0,0 +1,30 @@
+package mount
+
+// Usage the space and inodes of a filesystem, as df(1) tells them
+type Usage struct {
+	// BlockSize the size of the blocks the filesystem counts its space in
+	BlockSize int64
+	// Total the size of the filesystem in bytes
+	Total uint64
+	// Free the bytes free, those reserved for root included
+	Free uint64
+	// Available the bytes free to unprivileged users
+	Available uint64
+	// Used the bytes in use
+	Used uint64
+	// Files the inodes of the filesystem, zero if it does not count them, eg: btrfs
+	Files uint64
+	// FilesFree the inodes free
+	FilesFree uint64
+	// FilesUsed the inodes in use
+	FilesUsed uint64
+}
+
+// UsedPercent the percentage of the space in use, like df's Use%: of the used and available bytes,
+// so the space reserved for root does not count
+func (u Usage) UsedPercent() float64 {
+	if u.Used+u.Available == 0 {
+		return 0
+	}
+	return float64(u.Used) * 100 / float64(u.Used+u.Available)
+}
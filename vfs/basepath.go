@@ -0,0 +1,158 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// basePathFS an FS seen from a base directory of another
+type basePathFS struct {
+	fs   FS
+	base string
+}
+
+// NewBasePathFS an FS of the files under base in fsys, with base as its root
+// directory: names are cleaned with '..' stopping at the root, so they never
+// lead out of base lexically. symlinks are still resolved by fsys, absolute
+// targets of those created through it are kept under base, but a relative
+// target climbing out with '..' escapes, see dir.SecureJoin for that.
+func NewBasePathFS(fsys FS, base string) FS {
+	return &basePathFS{fs: fsys, base: filepath.Clean(base)}
+}
+
+// real the path of name in the underlying FS
+func (b *basePathFS) real(name string) string {
+	return filepath.Join(b.base, filepath.Clean(string(filepath.Separator)+name))
+}
+
+// virtual the path under base of the real path p, or p itself if it is not under base
+func (b *basePathFS) virtual(p string) string {
+	rel, err := filepath.Rel(b.base, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return p
+	}
+	return filepath.Join(string(filepath.Separator), rel)
+}
+
+// pathErr err with the real paths in it replaced by name, so base never shows
+func (b *basePathFS) pathErr(err error, name string) error {
+	if e, ok := err.(*os.PathError); ok {
+		return &os.PathError{Op: e.Op, Path: name, Err: e.Err}
+	}
+	return err
+}
+
+func (b *basePathFS) linkErr(err error, oldname, newname string) error {
+	if e, ok := err.(*os.LinkError); ok {
+		return &os.LinkError{Op: e.Op, Old: oldname, New: newname, Err: e.Err}
+	}
+	return err
+}
+
+func (b *basePathFS) Open(name string) (File, error) {
+	f, err := b.fs.Open(b.real(name))
+	if err != nil {
+		return nil, b.pathErr(err, name)
+	}
+	return &basePathFile{File: f, name: name}, nil
+}
+
+func (b *basePathFS) Create(name string) (File, error) {
+	f, err := b.fs.Create(b.real(name))
+	if err != nil {
+		return nil, b.pathErr(err, name)
+	}
+	return &basePathFile{File: f, name: name}, nil
+}
+
+func (b *basePathFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := b.fs.OpenFile(b.real(name), flag, perm)
+	if err != nil {
+		return nil, b.pathErr(err, name)
+	}
+	return &basePathFile{File: f, name: name}, nil
+}
+
+func (b *basePathFS) Mkdir(name string, perm os.FileMode) error {
+	return b.pathErr(b.fs.Mkdir(b.real(name), perm), name)
+}
+
+func (b *basePathFS) MkdirAll(name string, perm os.FileMode) error {
+	return b.pathErr(b.fs.MkdirAll(b.real(name), perm), name)
+}
+
+func (b *basePathFS) Remove(name string) error {
+	return b.pathErr(b.fs.Remove(b.real(name)), name)
+}
+
+func (b *basePathFS) RemoveAll(name string) error {
+	p := b.real(name)
+	if p == b.base {
+		// keep base itself, which is the root directory here
+		items, err := ReadDir(b.fs, p)
+		if err != nil {
+			return b.pathErr(err, name)
+		}
+		for _, v := range items {
+			err = b.fs.RemoveAll(filepath.Join(p, v.Name()))
+			if err != nil {
+				return b.pathErr(err, filepath.Join(name, v.Name()))
+			}
+		}
+		return nil
+	}
+	return b.pathErr(b.fs.RemoveAll(p), name)
+}
+
+func (b *basePathFS) Rename(oldname, newname string) error {
+	return b.linkErr(b.fs.Rename(b.real(oldname), b.real(newname)), oldname, newname)
+}
+
+func (b *basePathFS) Symlink(oldname, newname string) error {
+	target := oldname
+	if filepath.IsAbs(target) {
+		target = b.real(target)
+	}
+	return b.linkErr(b.fs.Symlink(target, b.real(newname)), oldname, newname)
+}
+
+func (b *basePathFS) Readlink(name string) (string, error) {
+	target, err := b.fs.Readlink(b.real(name))
+	if err != nil {
+		return "", b.pathErr(err, name)
+	}
+	if filepath.IsAbs(target) {
+		target = b.virtual(target)
+	}
+	return target, nil
+}
+
+func (b *basePathFS) Stat(name string) (os.FileInfo, error) {
+	fi, err := b.fs.Stat(b.real(name))
+	return fi, b.pathErr(err, name)
+}
+
+func (b *basePathFS) Lstat(name string) (os.FileInfo, error) {
+	fi, err := b.fs.Lstat(b.real(name))
+	return fi, b.pathErr(err, name)
+}
+
+func (b *basePathFS) Chmod(name string, mode os.FileMode) error {
+	return b.pathErr(b.fs.Chmod(b.real(name), mode), name)
+}
+
+func (b *basePathFS) Chtimes(name string, atime, mtime time.Time) error {
+	return b.pathErr(b.fs.Chtimes(b.real(name), atime, mtime), name)
+}
+
+// basePathFile a File of a basePathFS, named as it was opened there
+type basePathFile struct {
+	File
+	name string
+}
+
+func (f *basePathFile) Name() string {
+	return f.name
+}
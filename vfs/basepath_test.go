@@ -0,0 +1,61 @@
+package vfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBasePathFS(t *testing.T) {
+	m := NewMemFS()
+	m.MkdirAll("/chroot/etc", 0755)
+	m.MkdirAll("/etc", 0755)
+	f, _ := m.Create("/etc/passwd")
+	f.Close()
+
+	b := NewBasePathFS(m, "/chroot")
+	f, err := b.Create("../../etc/passwd")
+	if err != nil {
+		t.Fatalf("[vfs]BasePathFS.Create failed: %v", err)
+	}
+	if f.Name() != "../../etc/passwd" {
+		t.Errorf("[vfs]BasePathFS.Create test failed, expecting the File named ../../etc/passwd, got %s", f.Name())
+	}
+	f.Write([]byte("root"))
+	f.Close()
+
+	if fi, _ := m.Stat("/etc/passwd"); fi.Size() != 0 {
+		t.Errorf("[vfs]BasePathFS.Create test failed, expecting /etc/passwd left alone, got %d bytes", fi.Size())
+	}
+	f, _ = m.Open("/chroot/etc/passwd")
+	content, _ := ioutil.ReadAll(f)
+	f.Close()
+	if string(content) != "root" {
+		t.Errorf("[vfs]BasePathFS.Create test failed, expecting /chroot/etc/passwd written, got %q", content)
+	}
+
+	// absolute symlink targets stay under base
+	b.Symlink("/etc", "/link")
+	if target, err := m.Readlink("/chroot/link"); err != nil || target != "/chroot/etc" {
+		t.Errorf("[vfs]BasePathFS.Symlink test failed, expecting /chroot/etc, got %s, err %v", target, err)
+	}
+	if target, err := b.Readlink("/link"); err != nil || target != string(filepath.Separator)+"etc" {
+		t.Errorf("[vfs]BasePathFS.Readlink test failed, expecting /etc, got %s, err %v", target, err)
+	}
+	if _, err := b.Stat("/link/passwd"); err != nil {
+		t.Errorf("[vfs]BasePathFS.Stat test failed, expecting /etc/passwd through /link, got err %v", err)
+	}
+
+	_, err = b.Stat("/missing")
+	if e, ok := err.(*os.PathError); !ok || e.Path != "/missing" || !os.IsNotExist(err) {
+		t.Errorf("[vfs]BasePathFS.Stat test failed, expecting os.ErrNotExist for /missing, got %v", err)
+	}
+
+	if err := b.RemoveAll("/"); err != nil {
+		t.Errorf("[vfs]BasePathFS.RemoveAll test failed: %v", err)
+	}
+	if items, err := ReadDir(m, "/chroot"); err != nil || len(items) != 0 {
+		t.Errorf("[vfs]BasePathFS.RemoveAll test failed, expecting an empty /chroot, got %v, err %v", items, err)
+	}
+}
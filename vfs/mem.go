@@ -0,0 +1,619 @@
+package vfs
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// maxSymlinkHops the symlinks followed while resolving a path before giving up with ELOOP
+const maxSymlinkHops = 40
+
+// MemFS a filesystem kept in memory, safe for concurrent use. paths are slash
+// separated, relative paths are relative to the root directory. files and
+// directories are owned by nobody in particular, so permissions are kept but
+// not enforced.
+type MemFS struct {
+	mu sync.RWMutex
+	// nodes by their clean absolute path, the root directory included
+	nodes map[string]*memNode
+}
+
+type memNode struct {
+	mode   os.FileMode
+	data   []byte
+	target string
+	atime  time.Time
+	mtime  time.Time
+}
+
+// NewMemFS an empty MemFS, with nothing but the root directory
+func NewMemFS() *MemFS {
+	now := time.Now()
+	return &MemFS{nodes: map[string]*memNode{
+		"/": {mode: os.ModeDir | 0755, atime: now, mtime: now},
+	}}
+}
+
+// memPath the absolute path of name. it is not cleaned, '..' after a symlink
+// must leave the directory the symlink leads to, not the one it is in.
+func memPath(name string) string {
+	return "/" + filepath.ToSlash(name)
+}
+
+func splitMemPath(p string) []string {
+	return strings.Split(filepath.ToSlash(p), "/")
+}
+
+// onlyDots whether comps leads nowhere further
+func onlyDots(comps []string) bool {
+	for _, v := range comps {
+		if v != "" && v != "." {
+			return false
+		}
+	}
+	return true
+}
+
+// resolve the path name leads to, with the node there or nil if it is missing.
+// symlinks leading on are followed, the last one only with follow or a trailing
+// slash. the parent of a missing node exists.
+func (m *MemFS) resolve(op, name string, follow bool) (string, *memNode, error) {
+	resolved := "/"
+	remaining := splitMemPath(memPath(name))
+	hops := 0
+
+	for len(remaining) > 0 {
+		comp := remaining[0]
+		remaining = remaining[1:]
+
+		if !m.nodes[resolved].mode.IsDir() {
+			return "", nil, &os.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+		}
+		switch comp {
+		case "", ".":
+			continue
+		case "..":
+			resolved = path.Dir(resolved)
+			continue
+		}
+
+		next := path.Join(resolved, comp)
+		last := onlyDots(remaining)
+		n, ok := m.nodes[next]
+		if !ok {
+			if last {
+				return next, nil, nil
+			}
+			return "", nil, &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+		}
+		if n.mode&os.ModeSymlink == 0 || (len(remaining) == 0 && !follow) {
+			resolved = next
+			continue
+		}
+
+		hops++
+		if hops > maxSymlinkHops {
+			return "", nil, &os.PathError{Op: op, Path: name, Err: syscall.ELOOP}
+		}
+		if path.IsAbs(n.target) {
+			resolved = "/"
+		}
+		remaining = append(splitMemPath(n.target), remaining...)
+	}
+	return resolved, m.nodes[resolved], nil
+}
+
+// children the names of the entries of the directory p, sorted
+func (m *MemFS) children(p string) []string {
+	var names []string
+	for k := range m.nodes {
+		if k != "/" && path.Dir(k) == p {
+			names = append(names, path.Base(k))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open open name for reading
+func (m *MemFS) Open(name string) (File, error) {
+	return m.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Create create or truncate name for reading and writing, with mode 0666
+func (m *MemFS) Create(name string) (File, error) {
+	return m.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// OpenFile open name with flag, creating it with perm if O_CREATE is given
+func (m *MemFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, n, err := m.resolve("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	writable := flag&(os.O_WRONLY|os.O_RDWR) != 0
+
+	switch {
+	case n == nil:
+		if flag&os.O_CREATE == 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		now := time.Now()
+		n = &memNode{mode: perm & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky), atime: now, mtime: now}
+		m.nodes[p] = n
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+	case n.mode.IsDir() && writable:
+		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+	case flag&os.O_TRUNC != 0 && writable:
+		n.data = nil
+		n.mtime = time.Now()
+	}
+	return &memFile{fs: m, name: name, path: p, node: n, flag: flag}, nil
+}
+
+// Mkdir create the directory name with perm
+func (m *MemFS) Mkdir(name string, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mkdir(name, perm)
+}
+
+func (m *MemFS) mkdir(name string, perm os.FileMode) error {
+	p, n, err := m.resolve("mkdir", name, false)
+	if err != nil {
+		return err
+	}
+	if n != nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+	now := time.Now()
+	m.nodes[p] = &memNode{mode: os.ModeDir | perm&(os.ModePerm|os.ModeSetgid|os.ModeSticky), atime: now, mtime: now}
+	m.nodes[path.Dir(p)].mtime = now
+	return nil
+}
+
+// MkdirAll create the directory name with its missing parents, all with perm
+func (m *MemFS) MkdirAll(name string, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := memPath(name)
+	comps := splitMemPath(p)
+	for i := 2; i <= len(comps); i++ {
+		prefix := strings.Join(comps[:i], "/")
+		_, n, err := m.resolve("mkdir", prefix, true)
+		if err != nil {
+			return err
+		}
+		if n == nil {
+			err = m.mkdir(prefix, perm)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if !n.mode.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+		}
+	}
+	return nil
+}
+
+// Remove remove the file or empty directory name
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, n, err := m.resolve("remove", name, false)
+	if err != nil {
+		return err
+	}
+	if n == nil {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	if p == "/" {
+		return &os.PathError{Op: "remove", Path: name, Err: syscall.EBUSY}
+	}
+	if n.mode.IsDir() && len(m.children(p)) > 0 {
+		return &os.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+	}
+	delete(m.nodes, p)
+	m.nodes[path.Dir(p)].mtime = time.Now()
+	return nil
+}
+
+// RemoveAll remove name with everything under it, a missing name is no error
+func (m *MemFS) RemoveAll(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, n, err := m.resolve("removeall", name, false)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if n == nil {
+		return nil
+	}
+	if p == "/" {
+		return &os.PathError{Op: "removeall", Path: name, Err: syscall.EBUSY}
+	}
+	for k := range m.nodes {
+		if k == p || strings.HasPrefix(k, p+"/") {
+			delete(m.nodes, k)
+		}
+	}
+	m.nodes[path.Dir(p)].mtime = time.Now()
+	return nil
+}
+
+// Rename move oldname to newname, replacing a file or an empty directory there
+func (m *MemFS) Rename(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	linkErr := func(err error) error {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	op, on, err := m.resolve("rename", oldname, false)
+	if err != nil {
+		return linkErr(err.(*os.PathError).Err)
+	}
+	if on == nil {
+		return linkErr(os.ErrNotExist)
+	}
+	np, nn, err := m.resolve("rename", newname, false)
+	if err != nil {
+		return linkErr(err.(*os.PathError).Err)
+	}
+	if op == np {
+		return nil
+	}
+	if op == "/" || strings.HasPrefix(np, op+"/") {
+		return linkErr(syscall.EINVAL)
+	}
+	if nn != nil {
+		switch {
+		case nn.mode.IsDir() && !on.mode.IsDir():
+			return linkErr(syscall.EISDIR)
+		case !nn.mode.IsDir() && on.mode.IsDir():
+			return linkErr(syscall.ENOTDIR)
+		case nn.mode.IsDir() && len(m.children(np)) > 0:
+			return linkErr(syscall.ENOTEMPTY)
+		}
+	}
+
+	for k, v := range m.nodes {
+		if k == op || strings.HasPrefix(k, op+"/") {
+			delete(m.nodes, k)
+			m.nodes[np+k[len(op):]] = v
+		}
+	}
+	now := time.Now()
+	m.nodes[path.Dir(op)].mtime = now
+	m.nodes[path.Dir(np)].mtime = now
+	return nil
+}
+
+// Symlink create newname as a symlink to oldname
+func (m *MemFS) Symlink(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, n, err := m.resolve("symlink", newname, false)
+	if err != nil {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: err.(*os.PathError).Err}
+	}
+	if n != nil {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: os.ErrExist}
+	}
+	now := time.Now()
+	m.nodes[p] = &memNode{mode: os.ModeSymlink | os.ModePerm, target: filepath.ToSlash(oldname), atime: now, mtime: now}
+	m.nodes[path.Dir(p)].mtime = now
+	return nil
+}
+
+// Readlink the target of the symlink name
+func (m *MemFS) Readlink(name string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, n, err := m.resolve("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if n == nil {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: os.ErrNotExist}
+	}
+	if n.mode&os.ModeSymlink == 0 {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: syscall.EINVAL}
+	}
+	return n.target, nil
+}
+
+// Stat the FileInfo of name, symlinks are followed
+func (m *MemFS) Stat(name string) (os.FileInfo, error) {
+	return m.stat("stat", name, true)
+}
+
+// Lstat the FileInfo of name, a symlink is described itself
+func (m *MemFS) Lstat(name string) (os.FileInfo, error) {
+	return m.stat("lstat", name, false)
+}
+
+func (m *MemFS) stat(op, name string, follow bool) (os.FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	p, n, err := m.resolve(op, name, follow)
+	if err != nil {
+		return nil, err
+	}
+	if n == nil {
+		return nil, &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	return n.info(path.Base(p)), nil
+}
+
+// Chmod change the permission bits of name, symlinks are followed
+func (m *MemFS) Chmod(name string, mode os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, n, err := m.resolve("chmod", name, true)
+	if err != nil {
+		return err
+	}
+	if n == nil {
+		return &os.PathError{Op: "chmod", Path: name, Err: os.ErrNotExist}
+	}
+	n.mode = n.mode&os.ModeType | mode&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)
+	return nil
+}
+
+// Chtimes change the access and modification times of name, symlinks are followed
+func (m *MemFS) Chtimes(name string, atime, mtime time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, n, err := m.resolve("chtimes", name, true)
+	if err != nil {
+		return err
+	}
+	if n == nil {
+		return &os.PathError{Op: "chtimes", Path: name, Err: os.ErrNotExist}
+	}
+	n.atime, n.mtime = atime, mtime
+	return nil
+}
+
+func (n *memNode) info(name string) os.FileInfo {
+	size := int64(len(n.data))
+	if n.mode&os.ModeSymlink != 0 {
+		size = int64(len(n.target))
+	}
+	return &memInfo{name: name, size: size, mode: n.mode, mtime: n.mtime}
+}
+
+// memInfo the os.FileInfo of a MemFS node
+type memInfo struct {
+	name  string
+	size  int64
+	mode  os.FileMode
+	mtime time.Time
+}
+
+func (i *memInfo) Name() string       { return i.name }
+func (i *memInfo) Size() int64        { return i.size }
+func (i *memInfo) Mode() os.FileMode  { return i.mode }
+func (i *memInfo) ModTime() time.Time { return i.mtime }
+func (i *memInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memInfo) Sys() interface{}   { return nil }
+
+// memFile an open MemFS file, which keeps its content after being removed like on Unix
+type memFile struct {
+	fs     *MemFS
+	name   string
+	path   string
+	node   *memNode
+	flag   int
+	offset int64
+	// read the directory entries already returned by Readdir
+	read   int
+	closed bool
+}
+
+func (f *memFile) Name() string {
+	return f.name
+}
+
+// check the error of op on f, nil if f is open and, for writing ops, writable
+func (f *memFile) check(op string, write bool) error {
+	if f.closed {
+		return &os.PathError{Op: op, Path: f.name, Err: os.ErrClosed}
+	}
+	if write && f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return &os.PathError{Op: op, Path: f.name, Err: syscall.EBADF}
+	}
+	if !write && f.flag&os.O_WRONLY != 0 {
+		return &os.PathError{Op: op, Path: f.name, Err: syscall.EBADF}
+	}
+	return nil
+}
+
+func (f *memFile) Read(b []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if err := f.check("read", false); err != nil {
+		return 0, err
+	}
+	if f.node.mode.IsDir() {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: syscall.EISDIR}
+	}
+	if f.offset >= int64(len(f.node.data)) {
+		if len(b) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	n := copy(b, f.node.data[f.offset:])
+	f.offset += int64(n)
+	f.node.atime = time.Now()
+	return n, nil
+}
+
+func (f *memFile) Write(b []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if err := f.check("write", true); err != nil {
+		return 0, err
+	}
+	if f.flag&os.O_APPEND != 0 {
+		f.offset = int64(len(f.node.data))
+	}
+	end := f.offset + int64(len(b))
+	if end > int64(len(f.node.data)) {
+		data := make([]byte, end)
+		copy(data, f.node.data)
+		f.node.data = data
+	}
+	copy(f.node.data[f.offset:], b)
+	f.offset = end
+	f.node.mtime = time.Now()
+	return len(b), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if f.closed {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: os.ErrClosed}
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.node.data))
+	}
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: syscall.EINVAL}
+	}
+	f.offset = offset
+	// seeking to the start of a directory starts reading it over
+	if offset == 0 {
+		f.read = 0
+	}
+	return offset, nil
+}
+
+func (f *memFile) Close() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if f.closed {
+		return &os.PathError{Op: "close", Path: f.name, Err: os.ErrClosed}
+	}
+	f.closed = true
+	return nil
+}
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+
+	if f.closed {
+		return nil, &os.PathError{Op: "stat", Path: f.name, Err: os.ErrClosed}
+	}
+	return f.node.info(path.Base(f.path)), nil
+}
+
+// Readdir the FileInfo of the next n entries of the directory, all that are left if n <= 0
+func (f *memFile) Readdir(n int) ([]os.FileInfo, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if err := f.check("readdirent", false); err != nil {
+		return nil, err
+	}
+	if !f.node.mode.IsDir() {
+		return nil, &os.PathError{Op: "readdirent", Path: f.name, Err: syscall.ENOTDIR}
+	}
+
+	// a directory removed since has no entries
+	var names []string
+	if f.fs.nodes[f.path] == f.node {
+		names = f.fs.children(f.path)
+	}
+	if f.read > len(names) {
+		f.read = len(names)
+	}
+	names = names[f.read:]
+	if n > 0 {
+		if len(names) == 0 {
+			return nil, io.EOF
+		}
+		if n < len(names) {
+			names = names[:n]
+		}
+	}
+	f.read += len(names)
+
+	items := make([]os.FileInfo, 0, len(names))
+	for _, v := range names {
+		items = append(items, f.fs.nodes[path.Join(f.path, v)].info(v))
+	}
+	return items, nil
+}
+
+// Readdirnames the names of the next n entries of the directory, all that are left if n <= 0
+func (f *memFile) Readdirnames(n int) ([]string, error) {
+	items, err := f.Readdir(n)
+	names := make([]string, 0, len(items))
+	for _, v := range items {
+		names = append(names, v.Name())
+	}
+	return names, err
+}
+
+func (f *memFile) Sync() error {
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+
+	if f.closed {
+		return &os.PathError{Op: "sync", Path: f.name, Err: os.ErrClosed}
+	}
+	return nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if err := f.check("truncate", true); err != nil {
+		return err
+	}
+	if size < 0 {
+		return &os.PathError{Op: "truncate", Path: f.name, Err: syscall.EINVAL}
+	}
+	data := make([]byte, size)
+	copy(data, f.node.data)
+	f.node.data = data
+	f.node.mtime = time.Now()
+	return nil
+}
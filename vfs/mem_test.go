@@ -0,0 +1,170 @@
+package vfs
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestMemFSFiles(t *testing.T) {
+	m := NewMemFS()
+	err := m.MkdirAll("/a/b", 0755)
+	if err != nil {
+		t.Fatalf("[vfs]MemFS.MkdirAll failed: %v", err)
+	}
+
+	f, err := m.Create("a/b/c.txt")
+	if err != nil {
+		t.Fatalf("[vfs]MemFS.Create failed: %v", err)
+	}
+	io.WriteString(f, "hello world")
+	f.Seek(6, io.SeekStart)
+	io.WriteString(f, "there")
+	f.Close()
+	if _, err := f.Write([]byte("x")); err == nil {
+		t.Errorf("[vfs]memFile.Write test failed, expecting an error on a closed file, got nil")
+	}
+
+	f, _ = m.Open("/a/b/c.txt")
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil || string(b) != "hello there" {
+		t.Errorf("[vfs]MemFS.Open test failed, expecting %q, got %q, err %v", "hello there", b, err)
+	}
+
+	f, _ = m.OpenFile("/a/b/c.txt", os.O_WRONLY|os.O_APPEND, 0)
+	io.WriteString(f, "!")
+	f.Truncate(5)
+	f.Close()
+	fi, err := m.Stat("/a/b/c.txt")
+	if err != nil || fi.Size() != 5 || fi.Mode() != 0666 || fi.Name() != "c.txt" {
+		t.Errorf("[vfs]MemFS.Stat test failed, expecting c.txt of 5 bytes and mode 0666, got %v, err %v", fi, err)
+	}
+
+	if _, err := m.OpenFile("/a/b/c.txt", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); !os.IsExist(err) {
+		t.Errorf("[vfs]MemFS.OpenFile test failed, expecting os.ErrExist, got %v", err)
+	}
+	if _, err := m.Open("/a/missing"); !os.IsNotExist(err) {
+		t.Errorf("[vfs]MemFS.Open test failed, expecting os.ErrNotExist, got %v", err)
+	}
+	if _, err := m.Create("/a/b/c.txt/d"); err == nil || err.(*os.PathError).Err != syscall.ENOTDIR {
+		t.Errorf("[vfs]MemFS.Create test failed, expecting ENOTDIR, got %v", err)
+	}
+	if _, err := m.OpenFile("/a", os.O_WRONLY, 0); err == nil {
+		t.Errorf("[vfs]MemFS.OpenFile test failed, expecting EISDIR, got nil")
+	}
+
+	mtime := time.Date(2021, 6, 30, 12, 0, 0, 0, time.UTC)
+	m.Chmod("/a/b/c.txt", 0600)
+	m.Chtimes("/a/b/c.txt", mtime, mtime)
+	fi, _ = m.Stat("/a/b/c.txt")
+	if fi.Mode() != 0600 || !fi.ModTime().Equal(mtime) {
+		t.Errorf("[vfs]MemFS.Chmod test failed, expecting mode 0600 and mtime %v, got %v %v", mtime, fi.Mode(), fi.ModTime())
+	}
+}
+
+func TestMemFSDirectories(t *testing.T) {
+	m := NewMemFS()
+	m.MkdirAll("/d/sub", 0755)
+	for _, v := range []string{"/d/c", "/d/a", "/d/b"} {
+		f, _ := m.Create(v)
+		f.Close()
+	}
+
+	if err := m.Mkdir("/d", 0755); !os.IsExist(err) {
+		t.Errorf("[vfs]MemFS.Mkdir test failed, expecting os.ErrExist, got %v", err)
+	}
+	if err := m.MkdirAll("/d/a/x", 0755); err == nil {
+		t.Errorf("[vfs]MemFS.MkdirAll test failed, expecting ENOTDIR, got nil")
+	}
+
+	f, _ := m.Open("/d")
+	first, err := f.Readdirnames(2)
+	rest, err1 := f.Readdirnames(2)
+	_, err2 := f.Readdirnames(2)
+	f.Close()
+	if !reflect.DeepEqual(first, []string{"a", "b"}) || !reflect.DeepEqual(rest, []string{"c", "sub"}) ||
+		err != nil || err1 != nil || err2 != io.EOF {
+		t.Errorf("[vfs]memFile.Readdirnames test failed, expecting [a b] [c sub] and io.EOF, got %v %v, err %v %v %v", first, rest, err, err1, err2)
+	}
+
+	if err := m.Remove("/d"); err == nil {
+		t.Errorf("[vfs]MemFS.Remove test failed, expecting ENOTEMPTY, got nil")
+	}
+	if err := m.Rename("/d", "/e"); err != nil {
+		t.Errorf("[vfs]MemFS.Rename test failed: %v", err)
+	}
+	if _, err := m.Stat("/e/sub"); err != nil {
+		t.Errorf("[vfs]MemFS.Rename test failed, expecting /e/sub, got err %v", err)
+	}
+	if err := m.Rename("/e", "/e/sub/e"); err == nil {
+		t.Errorf("[vfs]MemFS.Rename test failed, expecting EINVAL moving a directory into itself, got nil")
+	}
+	if err := m.RemoveAll("/e"); err != nil {
+		t.Errorf("[vfs]MemFS.RemoveAll test failed: %v", err)
+	}
+	if len(m.nodes) != 1 {
+		t.Errorf("[vfs]MemFS.RemoveAll test failed, expecting only the root directory left, got %v", m.nodes)
+	}
+}
+
+func TestMemFSSymlinks(t *testing.T) {
+	m := NewMemFS()
+	m.MkdirAll("/usr/lib", 0755)
+	f, _ := m.Create("/usr/lib/libfoo.so.1")
+	io.WriteString(f, "elf")
+	f.Close()
+	m.Symlink("libfoo.so.1", "/usr/lib/libfoo.so")
+	m.Symlink("/usr/lib", "/lib")
+	m.Symlink("loop", "/loop")
+
+	fi, err := m.Stat("/lib/libfoo.so")
+	if err != nil || !fi.Mode().IsRegular() || fi.Size() != 3 {
+		t.Errorf("[vfs]MemFS.Stat test failed, expecting the regular file symlinks lead to, got %v, err %v", fi, err)
+	}
+	fi, err = m.Lstat("/lib/libfoo.so")
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("[vfs]MemFS.Lstat test failed, expecting a symlink, got %v, err %v", fi, err)
+	}
+	if target, err := m.Readlink("/lib/libfoo.so"); err != nil || target != "libfoo.so.1" {
+		t.Errorf("[vfs]MemFS.Readlink test failed, expecting libfoo.so.1, got %s, err %v", target, err)
+	}
+	// '..' is physical, it leaves the directory the symlink leads to
+	if _, err := m.Stat("/lib/../lib/libfoo.so.1"); err != nil {
+		t.Errorf("[vfs]MemFS.Stat test failed, expecting /usr/lib/libfoo.so.1, got err %v", err)
+	}
+	m.MkdirAll("/a/b/c", 0755)
+	m.MkdirAll("/x", 0755)
+	m.Create("/a/b/target")
+	m.Symlink("/a/b/c", "/x/l")
+	if fi, err := m.Stat("/x/l/../target"); err != nil || !fi.Mode().IsRegular() {
+		t.Errorf("[vfs]MemFS.Stat test failed, expecting /a/b/target, got %v, err %v", fi, err)
+	}
+	if _, err := m.Stat("/x/l/../../x"); !os.IsNotExist(err) {
+		t.Errorf("[vfs]MemFS.Stat test failed, expecting /a/x missing, got %v", err)
+	}
+	// a trailing slash follows the symlink
+	if fi, err := m.Lstat("/x/l/"); err != nil || !fi.IsDir() {
+		t.Errorf("[vfs]MemFS.Lstat test failed, expecting the directory /a/b/c, got %v, err %v", fi, err)
+	}
+	if _, err := m.Lstat("/a/b/target/"); err == nil || err.(*os.PathError).Err != syscall.ENOTDIR {
+		t.Errorf("[vfs]MemFS.Lstat test failed, expecting ENOTDIR, got %v", err)
+	}
+	if _, err := m.Stat("/loop"); err == nil || err.(*os.PathError).Err != syscall.ELOOP {
+		t.Errorf("[vfs]MemFS.Stat test failed, expecting ELOOP, got %v", err)
+	}
+
+	// a removed file stays readable through an open File, like on Unix
+	f, _ = m.Open("/usr/lib/libfoo.so.1")
+	m.Remove("/usr/lib/libfoo.so")
+	m.Remove("/usr/lib/libfoo.so.1")
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil || string(b) != "elf" {
+		t.Errorf("[vfs]memFile.Read test failed, expecting %q, got %q, err %v", "elf", b, err)
+	}
+}
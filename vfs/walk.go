@@ -0,0 +1,111 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/marguerite/go-stdlib/extglob"
+	"github.com/marguerite/go-stdlib/internal"
+)
+
+// ReadDir the entries of the directory dirname in fsys, sorted by name
+func ReadDir(fsys FS, dirname string) ([]os.FileInfo, error) {
+	f, err := fsys.Open(dirname)
+	if err != nil {
+		return nil, err
+	}
+	items, err := f.Readdir(-1)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name() < items[j].Name() })
+	return items, nil
+}
+
+// Walk filepath.Walk in fsys, symlinks are not followed
+func Walk(fsys FS, root string, fn filepath.WalkFunc) error {
+	fi, err := fsys.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walk(fsys, root, fi, fn)
+	}
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+func walk(fsys FS, path string, fi os.FileInfo, fn filepath.WalkFunc) error {
+	if !fi.IsDir() {
+		return fn(path, fi, nil)
+	}
+
+	items, err := ReadDir(fsys, path)
+	err1 := fn(path, fi, err)
+	// like filepath.Walk, fn sees the error of reading path and may skip it
+	if err != nil || err1 != nil {
+		return err1
+	}
+
+	for _, item := range items {
+		err = walk(fsys, filepath.Join(path, item.Name()), item, fn)
+		if err != nil {
+			if !item.IsDir() || err != filepath.SkipDir {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Expand expand the extglob pattern to the files and directories of fsys matching it
+func Expand(fsys FS, pattern string) ([]string, error) {
+	if fsys == OS {
+		return extglob.Expand(internal.Str2bytes(pattern))
+	}
+
+	list := func(p string, globalstar, tailing bool) ([]string, error) {
+		if globalstar {
+			var files []string
+			err := Walk(fsys, p, func(p1 string, fi os.FileInfo, err error) error {
+				if err != nil {
+					if os.IsPermission(err) {
+						return nil
+					}
+					return err
+				}
+				if !tailing || fi.IsDir() {
+					files = append(files, p1)
+				}
+				return nil
+			})
+			return files, err
+		}
+
+		fi, err := fsys.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			return []string{p}, nil
+		}
+		f, err := fsys.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		names, err := f.Readdirnames(-1)
+		for i := range names {
+			names[i] = filepath.Join(p, names[i])
+		}
+		return names, err
+	}
+	valid := func(p string) bool {
+		_, err := fsys.Stat(p)
+		return !os.IsNotExist(err)
+	}
+	return extglob.ExpandFunc(internal.Str2bytes(pattern), list, valid)
+}
@@ -0,0 +1,53 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newTestFS() *MemFS {
+	m := NewMemFS()
+	m.MkdirAll("/src/doc", 0755)
+	m.MkdirAll("/src/bin", 0755)
+	for _, v := range []string{"/src/a.go", "/src/b.go", "/src/doc/README.md", "/src/bin/tool"} {
+		f, _ := m.Create(v)
+		f.Close()
+	}
+	m.Symlink("doc", "/src/link")
+	return m
+}
+
+func TestWalk(t *testing.T) {
+	var paths []string
+	err := Walk(newTestFS(), "/src", func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Name() == "bin" {
+			return filepath.SkipDir
+		}
+		paths = append(paths, p)
+		return nil
+	})
+	correct := []string{"/src", "/src/a.go", "/src/b.go", "/src/doc", "/src/doc/README.md", "/src/link"}
+	if err != nil || !reflect.DeepEqual(paths, correct) {
+		t.Errorf("[vfs]Walk test failed, expecting %v, got %v, err %v", correct, paths, err)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	m := newTestFS()
+	tests := map[string][]string{
+		"/src/*.go":       {"/src/a.go", "/src/b.go"},
+		"/src/@(doc|bin)": {"/src/doc", "/src/bin"},
+		"/src/doc":        {"/src/doc"},
+	}
+	for pattern, correct := range tests {
+		matches, err := Expand(m, pattern)
+		if err != nil || !reflect.DeepEqual(matches, correct) {
+			t.Errorf("[vfs]Expand test failed for %s, expecting %v, got %v, err %v", pattern, correct, matches, err)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package xattr
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the attributes Linux keeps the access and default ACLs in
+const (
+	aclAccess  = "system.posix_acl_access"
+	aclDefault = "system.posix_acl_default"
+)
+
+// the binary format of the ACL attributes
+const (
+	aclVersion     = 2
+	aclUndefinedID = 0xffffffff
+	aclHeaderSize  = 4
+	aclEntrySize   = 8
+)
+
+// Tag the kind of an ACL entry
+type Tag uint16
+
+const (
+	// UserObj the owner of the file
+	UserObj Tag = 0x01
+	// User the user ID names
+	User Tag = 0x02
+	// GroupObj the group of the file
+	GroupObj Tag = 0x04
+	// Group the group ID names
+	Group Tag = 0x08
+	// Mask the most permissions User, GroupObj and Group entries grant
+	Mask Tag = 0x10
+	// Other everyone else
+	Other Tag = 0x20
+)
+
+func (t Tag) String() string {
+	switch t {
+	case UserObj, User:
+		return "user"
+	case GroupObj, Group:
+		return "group"
+	case Mask:
+		return "mask"
+	case Other:
+		return "other"
+	}
+	return fmt.Sprintf("Tag(%d)", int(t))
+}
+
+// Entry an entry of an ACL
+type Entry struct {
+	Tag Tag
+	// ID the uid of a User entry or the gid of a Group entry
+	ID int
+	// Perm the permissions granted, read 4, write 2 and execute 1
+	Perm os.FileMode
+}
+
+// ACL a POSIX access control list
+type ACL []Entry
+
+// ErrInvalidACL the ACL text or attribute is malformed
+var ErrInvalidACL = errors.New("invalid ACL")
+
+// FromMode the minimal ACL equivalent to the permission bits of mode
+func FromMode(mode os.FileMode) ACL {
+	return ACL{
+		{Tag: UserObj, Perm: mode >> 6 & 7},
+		{Tag: GroupObj, Perm: mode >> 3 & 7},
+		{Tag: Other, Perm: mode & 7},
+	}
+}
+
+// GetACL the access ACL of path. a file without one gets the ACL of its permission bits, like getfacl.
+func GetACL(path string) (ACL, error) {
+	b, err := Get(path, aclAccess)
+	if err != nil {
+		if !errors.Is(err, ErrNoAttribute) && !errors.Is(err, ErrNotSupported) {
+			return nil, err
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		return FromMode(fi.Mode()), nil
+	}
+	return decodeACL(b)
+}
+
+// GetDefaultACL the default ACL of the directory path, which new files in it inherit, nil if none
+func GetDefaultACL(path string) (ACL, error) {
+	b, err := Get(path, aclDefault)
+	if err != nil {
+		if errors.Is(err, ErrNoAttribute) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return decodeACL(b)
+}
+
+// SetACL set the access ACL of path. a Mask entry is added if named entries need one, like setfacl.
+func SetACL(path string, a ACL) error {
+	return Set(path, aclAccess, encodeACL(a.withMask()))
+}
+
+// SetDefaultACL set the default ACL of the directory path, an empty ACL removes it
+func SetDefaultACL(path string, a ACL) error {
+	if len(a) == 0 {
+		err := Remove(path, aclDefault)
+		if errors.Is(err, ErrNoAttribute) {
+			return nil
+		}
+		return err
+	}
+	return Set(path, aclDefault, encodeACL(a.withMask()))
+}
+
+// withMask a with a Mask entry granting the union of the group class permissions,
+// if it has named entries but no mask
+func (a ACL) withMask() ACL {
+	var named bool
+	var perm os.FileMode
+	for _, e := range a {
+		switch e.Tag {
+		case Mask:
+			return a
+		case User, Group:
+			named = true
+			perm |= e.Perm
+		case GroupObj:
+			perm |= e.Perm
+		}
+	}
+	if !named {
+		return a
+	}
+	return append(append(ACL{}, a...), Entry{Tag: Mask, Perm: perm})
+}
+
+// sorted a copy of a in the order the kernel wants: by tag, then by ID
+func (a ACL) sorted() ACL {
+	s := append(ACL{}, a...)
+	sort.SliceStable(s, func(i, j int) bool {
+		if s[i].Tag != s[j].Tag {
+			return s[i].Tag < s[j].Tag
+		}
+		return s[i].ID < s[j].ID
+	})
+	return s
+}
+
+func encodeACL(a ACL) []byte {
+	a = a.sorted()
+	b := make([]byte, aclHeaderSize+aclEntrySize*len(a))
+	binary.LittleEndian.PutUint32(b, aclVersion)
+	for i, e := range a {
+		p := b[aclHeaderSize+aclEntrySize*i:]
+		binary.LittleEndian.PutUint16(p, uint16(e.Tag))
+		binary.LittleEndian.PutUint16(p[2:], uint16(e.Perm&7))
+		id := uint32(aclUndefinedID)
+		if e.Tag == User || e.Tag == Group {
+			id = uint32(e.ID)
+		}
+		binary.LittleEndian.PutUint32(p[4:], id)
+	}
+	return b
+}
+
+func decodeACL(b []byte) (ACL, error) {
+	if len(b) < aclHeaderSize || (len(b)-aclHeaderSize)%aclEntrySize != 0 ||
+		binary.LittleEndian.Uint32(b) != aclVersion {
+		return nil, ErrInvalidACL
+	}
+	var a ACL
+	for p := b[aclHeaderSize:]; len(p) > 0; p = p[aclEntrySize:] {
+		e := Entry{
+			Tag:  Tag(binary.LittleEndian.Uint16(p)),
+			Perm: os.FileMode(binary.LittleEndian.Uint16(p[2:]) & 7),
+		}
+		if e.Tag == User || e.Tag == Group {
+			e.ID = int(binary.LittleEndian.Uint32(p[4:]))
+		}
+		a = append(a, e)
+	}
+	return a, nil
+}
+
+// ParseACL parse the text format of getfacl and setfacl, eg: "user::rw-",
+// "user:alice:r--", "g:100:rx", "mask::r-x", "other::---", one entry per line
+// or separated by commas. entries prefixed with "default:" or "d:" make up
+// the default ACL. comments and blank lines are ignored.
+func ParseACL(text string) (access, def ACL, err error) {
+	for _, line := range strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == ',' }) {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		fields := strings.Split(line, ":")
+		isDefault := false
+		if fields[0] == "default" || fields[0] == "d" {
+			isDefault = true
+			fields = fields[1:]
+		}
+		e, err := parseEntry(fields)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s", err, line)
+		}
+		if isDefault {
+			def = append(def, e)
+		} else {
+			access = append(access, e)
+		}
+	}
+	return access, def, nil
+}
+
+func parseEntry(fields []string) (Entry, error) {
+	var e Entry
+	// mask and other may omit the empty qualifier
+	if len(fields) == 2 {
+		fields = []string{fields[0], "", fields[1]}
+	}
+	if len(fields) != 3 {
+		return e, ErrInvalidACL
+	}
+
+	var err error
+	qualified := len(fields[1]) > 0
+	switch fields[0] {
+	case "user", "u":
+		e.Tag = UserObj
+		if qualified {
+			e.Tag = User
+			e.ID, err = lookupID(fields[1], true)
+		}
+	case "group", "g":
+		e.Tag = GroupObj
+		if qualified {
+			e.Tag = Group
+			e.ID, err = lookupID(fields[1], false)
+		}
+	case "mask", "m":
+		e.Tag = Mask
+	case "other", "o":
+		e.Tag = Other
+	default:
+		return e, ErrInvalidACL
+	}
+	if err != nil {
+		return e, err
+	}
+	if qualified && e.Tag != User && e.Tag != Group {
+		return e, ErrInvalidACL
+	}
+
+	e.Perm, err = parsePerm(fields[2])
+	return e, err
+}
+
+// parsePerm parse permissions like "rw-", "rx" or "6"
+func parsePerm(s string) (os.FileMode, error) {
+	if len(s) == 1 && s[0] >= '0' && s[0] <= '7' {
+		return os.FileMode(s[0] - '0'), nil
+	}
+	var perm os.FileMode
+	for _, c := range s {
+		switch c {
+		case 'r':
+			perm |= 4
+		case 'w':
+			perm |= 2
+		case 'x':
+			perm |= 1
+		case '-':
+		default:
+			return 0, ErrInvalidACL
+		}
+	}
+	return perm, nil
+}
+
+// lookupID the numeric id of a user or group name
+func lookupID(name string, isUser bool) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+	if isUser {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return 0, err
+		}
+		return strconv.Atoi(u.Uid)
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(g.Gid)
+}
+
+// FormatACL format the access and default ACLs in the text format of getfacl,
+// without its header. names are used for the IDs that have one.
+func FormatACL(access, def ACL) string {
+	var b strings.Builder
+	for _, e := range access.sorted() {
+		b.WriteString(formatEntry(e))
+		b.WriteByte('\n')
+	}
+	for _, e := range def.sorted() {
+		b.WriteString("default:")
+		b.WriteString(formatEntry(e))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func (a ACL) String() string {
+	return FormatACL(a, nil)
+}
+
+func formatEntry(e Entry) string {
+	var qualifier string
+	switch e.Tag {
+	case User:
+		qualifier = strconv.Itoa(e.ID)
+		if u, err := user.LookupId(qualifier); err == nil {
+			qualifier = u.Username
+		}
+	case Group:
+		qualifier = strconv.Itoa(e.ID)
+		if g, err := user.LookupGroupId(qualifier); err == nil {
+			qualifier = g.Name
+		}
+	}
+
+	perm := []byte("---")
+	for i, c := range "rwx" {
+		if e.Perm&(4>>uint(i)) != 0 {
+			perm[i] = byte(c)
+		}
+	}
+	return e.Tag.String() + ":" + qualifier + ":" + string(perm)
+}
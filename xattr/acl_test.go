@@ -0,0 +1,85 @@
+package xattr
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/marguerite/go-stdlib/dir"
+)
+
+func TestParseACL(t *testing.T) {
+	text := `# file: srv
+# owner: root
+user::rwx
+user:54321:r-x	#effective:r-x
+g::5
+mask:rx
+other::---
+default:user::rwx
+d:group::r-x
+default:other::r--
+`
+	access, def, err := ParseACL(text)
+	correct := ACL{
+		{Tag: UserObj, Perm: 7},
+		{Tag: User, ID: 54321, Perm: 5},
+		{Tag: GroupObj, Perm: 5},
+		{Tag: Mask, Perm: 5},
+		{Tag: Other},
+	}
+	correctDef := ACL{{Tag: UserObj, Perm: 7}, {Tag: GroupObj, Perm: 5}, {Tag: Other, Perm: 4}}
+	if err != nil || !reflect.DeepEqual(access, correct) || !reflect.DeepEqual(def, correctDef) {
+		t.Errorf("[xattr]ParseACL test failed, expecting %v and %v, got %v and %v, err %v", correct, correctDef, access, def, err)
+	}
+
+	formatted := FormatACL(access, def)
+	correctText := "user::rwx\nuser:54321:r-x\ngroup::r-x\nmask::r-x\nother::---\ndefault:user::rwx\ndefault:group::r-x\ndefault:other::r--\n"
+	if formatted != correctText {
+		t.Errorf("[xattr]FormatACL test failed, expecting %q, got %q", correctText, formatted)
+	}
+
+	for _, text := range []string{"user::rwz", "nobody::rwx", "other:54321:r--", "user"} {
+		if _, _, err := ParseACL(text); !errors.Is(err, ErrInvalidACL) {
+			t.Errorf("[xattr]ParseACL test failed for %q, expecting %v, got %v", text, ErrInvalidACL, err)
+		}
+	}
+}
+
+func TestACLBinary(t *testing.T) {
+	a := ACL{{Tag: Other, Perm: 4}, {Tag: User, ID: 1000, Perm: 6}, {Tag: UserObj, Perm: 6}, {Tag: GroupObj, Perm: 4}}
+	got, err := decodeACL(encodeACL(a.withMask()))
+	correct := ACL{
+		{Tag: UserObj, Perm: 6},
+		{Tag: User, ID: 1000, Perm: 6},
+		{Tag: GroupObj, Perm: 4},
+		{Tag: Mask, Perm: 6},
+		{Tag: Other, Perm: 4},
+	}
+	if err != nil || !reflect.DeepEqual(got, correct) {
+		t.Errorf("[xattr]ACL encoding test failed, expecting %v, got %v, err %v", correct, got, err)
+	}
+}
+
+func TestGetACL(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{{Path: "file", Mode: 0640}})
+	if err != nil {
+		t.Fatalf("[xattr]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	a, err := GetACL(tree.Path("file"))
+	if correct := FromMode(0640); err != nil || !reflect.DeepEqual(a, correct) {
+		t.Errorf("[xattr]GetACL test failed, expecting %v, got %v, err %v", correct, a, err)
+	}
+
+	named := append(a, Entry{Tag: Group, ID: 54321, Perm: 6})
+	err = SetACL(tree.Path("file"), named)
+	if errors.Is(err, ErrNotSupported) {
+		t.Skipf("[xattr]ACLs are not supported here: %v", err)
+	}
+	a, err = GetACL(tree.Path("file"))
+	if correct := named.withMask().sorted(); err != nil || !reflect.DeepEqual(a, correct) {
+		t.Errorf("[xattr]SetACL test failed, expecting %v, got %v, err %v", correct, a, err)
+	}
+}
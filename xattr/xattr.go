@@ -0,0 +1,44 @@
+// Package xattr get, set, list and remove extended attributes, and read and write
+// POSIX ACLs, which Linux keeps in the system.posix_acl_* attributes.
+// the L variants act on symlinks themselves instead of the files they point to.
+package xattr
+
+// Get the value of the extended attribute name of path
+func Get(path, name string) ([]byte, error) {
+	return get(path, name, false)
+}
+
+// LGet like Get, but symlinks are not followed
+func LGet(path, name string) ([]byte, error) {
+	return get(path, name, true)
+}
+
+// Set set the extended attribute name of path to value, creating or replacing it
+func Set(path, name string, value []byte) error {
+	return set(path, name, value, false)
+}
+
+// LSet like Set, but symlinks are not followed
+func LSet(path, name string, value []byte) error {
+	return set(path, name, value, true)
+}
+
+// List the names of the extended attributes of path
+func List(path string) ([]string, error) {
+	return list(path, false)
+}
+
+// LList like List, but symlinks are not followed
+func LList(path string) ([]string, error) {
+	return list(path, true)
+}
+
+// Remove remove the extended attribute name of path
+func Remove(path, name string) error {
+	return remove(path, name, false)
+}
+
+// LRemove like Remove, but symlinks are not followed
+func LRemove(path, name string) error {
+	return remove(path, name, true)
+}
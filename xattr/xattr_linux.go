@@ -0,0 +1,91 @@
+package xattr
+
+import (
+	"bytes"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	// ErrNotSupported the filesystem does not support extended attributes
+	ErrNotSupported error = unix.ENOTSUP
+	// ErrNoAttribute the extended attribute does not exist
+	ErrNoAttribute error = unix.ENODATA
+)
+
+func get(path, name string, nofollow bool) ([]byte, error) {
+	op, fn := "getxattr", unix.Getxattr
+	if nofollow {
+		op, fn = "lgetxattr", unix.Lgetxattr
+	}
+	for {
+		n, err := fn(path, name, nil)
+		if err != nil {
+			return nil, &os.PathError{Op: op, Path: path, Err: err}
+		}
+		buf := make([]byte, n)
+		n, err = fn(path, name, buf)
+		if err == unix.ERANGE {
+			// the value grew in between
+			continue
+		}
+		if err != nil {
+			return nil, &os.PathError{Op: op, Path: path, Err: err}
+		}
+		return buf[:n], nil
+	}
+}
+
+func set(path, name string, value []byte, nofollow bool) error {
+	op, fn := "setxattr", unix.Setxattr
+	if nofollow {
+		op, fn = "lsetxattr", unix.Lsetxattr
+	}
+	err := fn(path, name, value, 0)
+	if err != nil {
+		return &os.PathError{Op: op, Path: path, Err: err}
+	}
+	return nil
+}
+
+func list(path string, nofollow bool) ([]string, error) {
+	op, fn := "listxattr", unix.Listxattr
+	if nofollow {
+		op, fn = "llistxattr", unix.Llistxattr
+	}
+	for {
+		n, err := fn(path, nil)
+		if err != nil {
+			return nil, &os.PathError{Op: op, Path: path, Err: err}
+		}
+		buf := make([]byte, n)
+		n, err = fn(path, buf)
+		if err == unix.ERANGE {
+			continue
+		}
+		if err != nil {
+			return nil, &os.PathError{Op: op, Path: path, Err: err}
+		}
+
+		var names []string
+		for _, name := range bytes.Split(buf[:n], []byte{0}) {
+			if len(name) > 0 {
+				names = append(names, string(name))
+			}
+		}
+		return names, nil
+	}
+}
+
+func remove(path, name string, nofollow bool) error {
+	op, fn := "removexattr", unix.Removexattr
+	if nofollow {
+		op, fn = "lremovexattr", unix.Lremovexattr
+	}
+	err := fn(path, name)
+	if err != nil {
+		return &os.PathError{Op: op, Path: path, Err: err}
+	}
+	return nil
+}
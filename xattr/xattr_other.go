@@ -0,0 +1,31 @@
+// +build !linux
+
+package xattr
+
+import (
+	"errors"
+	"os"
+)
+
+var (
+	// ErrNotSupported extended attributes are only supported on Linux
+	ErrNotSupported = errors.New("extended attributes are not supported on this system")
+	// ErrNoAttribute the extended attribute does not exist
+	ErrNoAttribute = errors.New("no such attribute")
+)
+
+func get(path, name string, nofollow bool) ([]byte, error) {
+	return nil, &os.PathError{Op: "getxattr", Path: path, Err: ErrNotSupported}
+}
+
+func set(path, name string, value []byte, nofollow bool) error {
+	return &os.PathError{Op: "setxattr", Path: path, Err: ErrNotSupported}
+}
+
+func list(path string, nofollow bool) ([]string, error) {
+	return nil, &os.PathError{Op: "listxattr", Path: path, Err: ErrNotSupported}
+}
+
+func remove(path, name string, nofollow bool) error {
+	return &os.PathError{Op: "removexattr", Path: path, Err: ErrNotSupported}
+}
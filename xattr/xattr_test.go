@@ -0,0 +1,50 @@
+package xattr
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/marguerite/go-stdlib/dir"
+)
+
+func TestXattr(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "file", Content: "x"},
+		{Path: "link", Mode: os.ModeSymlink, Target: "file"},
+	})
+	if err != nil {
+		t.Fatalf("[xattr]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+
+	err = Set(tree.Path("link"), "user.test", []byte("value"))
+	if errors.Is(err, ErrNotSupported) {
+		t.Skipf("[xattr]extended attributes are not supported here: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("[xattr]Set test failed, expecting nil, got %v", err)
+	}
+
+	val, err := LGet(tree.Path("file"), "user.test")
+	if err != nil || string(val) != "value" {
+		t.Errorf("[xattr]LGet test failed, expecting value, got %s, err %v", val, err)
+	}
+	names, err := List(tree.Path("link"))
+	if err != nil || !reflect.DeepEqual(names, []string{"user.test"}) {
+		t.Errorf("[xattr]List test failed, expecting [user.test], got %v, err %v", names, err)
+	}
+	// user attributes can not be set on symlinks themselves
+	if names, err := LList(tree.Path("link")); err != nil || len(names) != 0 {
+		t.Errorf("[xattr]LList test failed, expecting [], got %v, err %v", names, err)
+	}
+
+	err = Remove(tree.Path("file"), "user.test")
+	if err != nil {
+		t.Errorf("[xattr]Remove test failed, expecting nil, got %v", err)
+	}
+	if _, err := Get(tree.Path("file"), "user.test"); !errors.Is(err, ErrNoAttribute) {
+		t.Errorf("[xattr]Get test failed, expecting %v, got %v", ErrNoAttribute, err)
+	}
+}
@@ -0,0 +1,114 @@
+package xdg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// the well-known user directories of xdg-user-dirs
+const (
+	Desktop     = "DESKTOP"
+	Download    = "DOWNLOAD"
+	Templates   = "TEMPLATES"
+	PublicShare = "PUBLICSHARE"
+	Documents   = "DOCUMENTS"
+	Music       = "MUSIC"
+	Pictures    = "PICTURES"
+	Videos      = "VIDEOS"
+)
+
+// ParseUserDirs parse the user-dirs.dirs format, eg: XDG_DESKTOP_DIR="$HOME/Desktop".
+// the returned map is keyed by the upper case name, eg: DESKTOP, and $HOME is
+// replaced by home. values must be absolute or relative to $HOME.
+func ParseUserDirs(r io.Reader, home string) (map[string]string, error) {
+	dirs := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	n := 0
+
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return dirs, fmt.Errorf("user-dirs.dirs line %d: missing '='", n)
+		}
+		key := strings.TrimSpace(line[:i])
+		if !strings.HasPrefix(key, "XDG_") || !strings.HasSuffix(key, "_DIR") || len(key) <= len("XDG__DIR") {
+			return dirs, fmt.Errorf("user-dirs.dirs line %d: invalid key %s", n, key)
+		}
+
+		val, err := unquote(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			return dirs, fmt.Errorf("user-dirs.dirs line %d: %v", n, err)
+		}
+
+		switch {
+		case val == "$HOME":
+			val = home
+		case strings.HasPrefix(val, "$HOME/"):
+			val = filepath.Join(home, val[len("$HOME/"):])
+		case filepath.IsAbs(val):
+		default:
+			return dirs, fmt.Errorf("user-dirs.dirs line %d: %s must be absolute or relative to $HOME", n, val)
+		}
+
+		dirs[key[len("XDG_"):len(key)-len("_DIR")]] = val
+	}
+
+	return dirs, scanner.Err()
+}
+
+// unquote strip the double quotes and shell escapes of a user-dirs.dirs value
+func unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("value %s is not double quoted", s)
+	}
+	s = s[1 : len(s)-1]
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i < len(s)-1 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String(), nil
+}
+
+// UserDirs the user directories configured in $XDG_CONFIG_HOME/user-dirs.dirs,
+// an empty map if the file does not exist
+func UserDirs() (map[string]string, error) {
+	f, err := os.Open(filepath.Join(ConfigHome(), "user-dirs.dirs"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	return ParseUserDirs(f, home())
+}
+
+// UserDir the user directory name like xdg-user-dir(1), eg: UserDir(Download).
+// unconfigured directories fall back to $HOME/Desktop for Desktop and $HOME for others.
+func UserDir(name string) string {
+	name = strings.ToUpper(name)
+	dirs, err := UserDirs()
+	if err == nil {
+		if val, ok := dirs[name]; ok {
+			return val
+		}
+	}
+	if name == Desktop {
+		return filepath.Join(home(), "Desktop")
+	}
+	return home()
+}
@@ -0,0 +1,146 @@
+// Package xdg implements https://specifications.freedesktop.org/basedir-spec/latest/
+// and the xdg-user-dirs configuration file.
+package xdg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/marguerite/go-stdlib/extglob"
+	"github.com/marguerite/go-stdlib/internal"
+)
+
+var (
+	ErrNoRuntimeDir = errors.New("XDG_RUNTIME_DIR is not set")
+)
+
+// home the user's home directory, falls back to / like the login shell does
+func home() string {
+	h, err := os.UserHomeDir()
+	if err != nil || len(h) == 0 {
+		return "/"
+	}
+	return h
+}
+
+// lookup return the value of environment variable key if it is an absolute path,
+// the spec says relative paths are invalid and should be ignored
+func lookup(key, def string) string {
+	val := os.Getenv(key)
+	if len(val) == 0 || !filepath.IsAbs(val) {
+		return def
+	}
+	return val
+}
+
+// lookupList like lookup but for a colon separated list, relative entries are dropped
+func lookupList(key string, def []string) []string {
+	var dirs []string
+	for _, v := range filepath.SplitList(os.Getenv(key)) {
+		if filepath.IsAbs(v) {
+			dirs = append(dirs, v)
+		}
+	}
+	if len(dirs) == 0 {
+		return def
+	}
+	return dirs
+}
+
+// ConfigHome $XDG_CONFIG_HOME, defaults to $HOME/.config
+func ConfigHome() string {
+	return lookup("XDG_CONFIG_HOME", filepath.Join(home(), ".config"))
+}
+
+// DataHome $XDG_DATA_HOME, defaults to $HOME/.local/share
+func DataHome() string {
+	return lookup("XDG_DATA_HOME", filepath.Join(home(), ".local", "share"))
+}
+
+// StateHome $XDG_STATE_HOME, defaults to $HOME/.local/state
+func StateHome() string {
+	return lookup("XDG_STATE_HOME", filepath.Join(home(), ".local", "state"))
+}
+
+// CacheHome $XDG_CACHE_HOME, defaults to $HOME/.cache
+func CacheHome() string {
+	return lookup("XDG_CACHE_HOME", filepath.Join(home(), ".cache"))
+}
+
+// BinHome the user's executable directory $HOME/.local/bin, it has no environment variable
+func BinHome() string {
+	return filepath.Join(home(), ".local", "bin")
+}
+
+// DataDirs $XDG_DATA_DIRS, defaults to /usr/local/share and /usr/share
+func DataDirs() []string {
+	return lookupList("XDG_DATA_DIRS", []string{"/usr/local/share", "/usr/share"})
+}
+
+// ConfigDirs $XDG_CONFIG_DIRS, defaults to /etc/xdg
+func ConfigDirs() []string {
+	return lookupList("XDG_CONFIG_DIRS", []string{"/etc/xdg"})
+}
+
+// RuntimeDir $XDG_RUNTIME_DIR. the spec has no default for it, and requires it
+// to be a directory owned by the user with permission 0700.
+func RuntimeDir() (string, error) {
+	val := lookup("XDG_RUNTIME_DIR", "")
+	if len(val) == 0 {
+		return "", ErrNoRuntimeDir
+	}
+
+	fi, err := os.Stat(val)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() {
+		return "", fmt.Errorf("XDG_RUNTIME_DIR %s is not a directory", val)
+	}
+	if fi.Mode().Perm() != 0700 {
+		return "", fmt.Errorf("XDG_RUNTIME_DIR %s has permission %v, expecting 0700", val, fi.Mode().Perm())
+	}
+	if !ownedByUser(fi) {
+		return "", fmt.Errorf("XDG_RUNTIME_DIR %s is not owned by the current user", val)
+	}
+	return val, nil
+}
+
+// search expand pattern relative to each directory, in the order of dirs
+func search(dirs []string, pattern string) ([]string, error) {
+	var files []string
+	for _, d := range dirs {
+		matches, err := extglob.Expand(internal.Str2bytes(filepath.Join(d, pattern)))
+		if err != nil {
+			return files, err
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// SearchConfigFiles find files matching the extglob pattern relative to ConfigHome
+// and ConfigDirs, most important first. eg: "fontconfig/conf.d/*.conf"
+func SearchConfigFiles(pattern string) ([]string, error) {
+	return search(append([]string{ConfigHome()}, ConfigDirs()...), pattern)
+}
+
+// SearchDataFiles find files matching the extglob pattern relative to DataHome
+// and DataDirs, most important first. eg: "applications/*.desktop"
+func SearchDataFiles(pattern string) ([]string, error) {
+	return search(append([]string{DataHome()}, DataDirs()...), pattern)
+}
+
+// ConfigFile the most important existing config file for the relative path,
+// or an os.ErrNotExist error if no candidate exists
+func ConfigFile(path string) (string, error) {
+	for _, d := range append([]string{ConfigHome()}, ConfigDirs()...) {
+		p := filepath.Join(d, path)
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", &os.PathError{Op: "lookup", Path: path, Err: os.ErrNotExist}
+}
@@ -0,0 +1,10 @@
+// +build !linux,!freebsd,!openbsd,!netbsd,!darwin
+
+package xdg
+
+import "os"
+
+// ownership can not be checked the unix way, trust the environment
+func ownedByUser(fi os.FileInfo) bool {
+	return true
+}
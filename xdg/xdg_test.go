@@ -0,0 +1,137 @@
+package xdg
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/marguerite/go-stdlib/dir"
+)
+
+// saveEnv save the environment variables keys, the returned func restores them
+func saveEnv(keys ...string) func() {
+	saved := make(map[string]*string, len(keys))
+	for _, k := range keys {
+		if v, ok := os.LookupEnv(k); ok {
+			saved[k] = &v
+		} else {
+			saved[k] = nil
+		}
+	}
+	return func() {
+		for k, v := range saved {
+			if v == nil {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, *v)
+			}
+		}
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	defer saveEnv("HOME", "XDG_CONFIG_HOME", "XDG_CACHE_HOME", "XDG_DATA_DIRS", "XDG_CONFIG_DIRS")()
+	os.Setenv("HOME", "/home/marguerite")
+	os.Setenv("XDG_CONFIG_HOME", "relative/is/invalid")
+	os.Unsetenv("XDG_CACHE_HOME")
+	os.Setenv("XDG_DATA_DIRS", "/opt/share:relative:/usr/share")
+	os.Unsetenv("XDG_CONFIG_DIRS")
+
+	if ConfigHome() != "/home/marguerite/.config" {
+		t.Errorf("[xdg]ConfigHome test failed, expecting /home/marguerite/.config, got %s", ConfigHome())
+	}
+	if CacheHome() != "/home/marguerite/.cache" {
+		t.Errorf("[xdg]CacheHome test failed, expecting /home/marguerite/.cache, got %s", CacheHome())
+	}
+	if correct := []string{"/opt/share", "/usr/share"}; !reflect.DeepEqual(DataDirs(), correct) {
+		t.Errorf("[xdg]DataDirs test failed, expecting %v, got %v", correct, DataDirs())
+	}
+	if correct := []string{"/etc/xdg"}; !reflect.DeepEqual(ConfigDirs(), correct) {
+		t.Errorf("[xdg]ConfigDirs test failed, expecting %v, got %v", correct, ConfigDirs())
+	}
+}
+
+func TestRuntimeDir(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "good", Mode: os.ModeDir | 0700},
+		{Path: "bad", Mode: os.ModeDir | 0755},
+	})
+	if err != nil {
+		t.Fatalf("[xdg]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+	defer saveEnv("XDG_RUNTIME_DIR")()
+
+	os.Setenv("XDG_RUNTIME_DIR", tree.Path("good"))
+	if p, err := RuntimeDir(); err != nil || p != tree.Path("good") {
+		t.Errorf("[xdg]RuntimeDir test failed, expecting %s, got %s, err %v", tree.Path("good"), p, err)
+	}
+	os.Setenv("XDG_RUNTIME_DIR", tree.Path("bad"))
+	if _, err := RuntimeDir(); err == nil {
+		t.Error("[xdg]RuntimeDir test failed, expecting error for permission 0755, got nil")
+	}
+	os.Unsetenv("XDG_RUNTIME_DIR")
+	if _, err := RuntimeDir(); err != ErrNoRuntimeDir {
+		t.Errorf("[xdg]RuntimeDir test failed, expecting ErrNoRuntimeDir, got %v", err)
+	}
+}
+
+func TestParseUserDirs(t *testing.T) {
+	s := `# This file is written by xdg-user-dirs-update
+XDG_DESKTOP_DIR="$HOME/Desktop"
+XDG_DOWNLOAD_DIR="$HOME/Down\"loads"
+XDG_PUBLICSHARE_DIR="/srv/public"
+XDG_TEMPLATES_DIR="$HOME"
+`
+	correct := map[string]string{
+		Desktop:     "/home/marguerite/Desktop",
+		Download:    "/home/marguerite/Down\"loads",
+		PublicShare: "/srv/public",
+		Templates:   "/home/marguerite",
+	}
+	dirs, err := ParseUserDirs(strings.NewReader(s), "/home/marguerite")
+	if err != nil || !reflect.DeepEqual(dirs, correct) {
+		t.Errorf("[xdg]ParseUserDirs test failed, expecting %v, got %v, err %v", correct, dirs, err)
+	}
+
+	if _, err := ParseUserDirs(strings.NewReader(`XDG_MUSIC_DIR="Music"`), "/home/marguerite"); err == nil {
+		t.Error("[xdg]ParseUserDirs test failed, expecting error for relative value, got nil")
+	}
+}
+
+func TestSearchConfigFiles(t *testing.T) {
+	tree, err := dir.TempTree(dir.TreeSpec{
+		{Path: "home/.config/app/user.conf"},
+		{Path: "etc/xdg/app/system.conf"},
+		{Path: "etc/xdg/app/README"},
+		{Path: "home/.config/user-dirs.dirs", Content: "XDG_MUSIC_DIR=\"$HOME/Music\"\n"},
+	})
+	if err != nil {
+		t.Fatalf("[xdg]TempTree failed: %v", err)
+	}
+	defer tree.Cleanup()
+	defer saveEnv("HOME", "XDG_CONFIG_HOME", "XDG_CONFIG_DIRS")()
+
+	os.Setenv("HOME", tree.Path("home"))
+	os.Unsetenv("XDG_CONFIG_HOME")
+	os.Setenv("XDG_CONFIG_DIRS", tree.Path("etc/xdg"))
+
+	correct := []string{tree.Path("home/.config/app/user.conf"), tree.Path("etc/xdg/app/system.conf")}
+	files, err := SearchConfigFiles(filepath.Join("app", "*.conf"))
+	if err != nil || !reflect.DeepEqual(files, correct) {
+		t.Errorf("[xdg]SearchConfigFiles test failed, expecting %v, got %v, err %v", correct, files, err)
+	}
+
+	if p, err := ConfigFile("app/README"); err != nil || p != tree.Path("etc/xdg/app/README") {
+		t.Errorf("[xdg]ConfigFile test failed, expecting %s, got %s, err %v", tree.Path("etc/xdg/app/README"), p, err)
+	}
+
+	if p := UserDir(Music); p != tree.Path("home/Music") {
+		t.Errorf("[xdg]UserDir test failed, expecting %s, got %s", tree.Path("home/Music"), p)
+	}
+	if p := UserDir(Desktop); p != tree.Path("home/Desktop") {
+		t.Errorf("[xdg]UserDir test failed, expecting %s, got %s", tree.Path("home/Desktop"), p)
+	}
+}
@@ -0,0 +1,16 @@
+// +build linux freebsd openbsd netbsd darwin
+
+package xdg
+
+import (
+	"os"
+	"syscall"
+)
+
+func ownedByUser(fi os.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false
+	}
+	return int(st.Uid) == os.Getuid()
+}